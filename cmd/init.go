@@ -15,7 +15,7 @@ var initCmd = &cobra.Command{
 	Long: `The configuration file enables you to import other config files and folders
 and control the flow of the application. It may soon become a requirement to the engine.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		engine.WriteConfig(&engine.GorgeSettings{}, engine.StandardConfig)
+		engine.WriteConfig(engine.DefaultSettings(), engine.StandardConfig)
 		fmt.Println("Configuration created...")
 	},
 }
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"time"
+)
+
+const (
+	// QueuePositionEvent tells a waiting client where it is in the login queue
+	QueuePositionEvent = "queue.position"
+)
+
+type (
+	// QueuePosition is sent to clients waiting in the login queue
+	QueuePosition struct {
+		Position int `json:"position"`
+		Waiting  int `json:"waiting"`
+	}
+)
+
+// Checks whether there is a free slot for the client, priority
+// clients can also take the reserved slots. Slots about to be
+// freed are counted as free. Called under the lock
+func (s *Server) admits(client *Client, freeing int) bool {
+	st := s.GM.Settings.Admission
+	admitted := s.admitted - freeing
+
+	if st.MaxClients <= 0 {
+		return true
+	}
+
+	if s.priority(client) {
+		return admitted < st.MaxClients
+	}
+
+	return admitted < st.MaxClients-st.Reserved
+}
+
+// Checks whether the client's claims give it a reserved slot
+func (s *Server) priority(client *Client) bool {
+	claim := s.GM.Settings.Admission.PriorityClaim
+	return claim != "" && client.Claims.Bool(claim)
+}
+
+// Gives the client its slot, called under the lock
+func (s *Server) admit(client *Client) {
+	client.setQueued(false)
+	client.Metadata.connected()
+	s.admitted++
+	s.Clients.Store(client.ID, client)
+	s.addSession(client)
+	s.issueToken(client)
+	s.issueUDPToken(client)
+}
+
+// Adds the client to the back of the login queue, called under
+// the lock. The position event is returned for the caller to push
+// once the lock is released. Positions are announced periodically
+// while anyone waits
+func (s *Server) enqueue(client *Client) Event {
+	client.setQueued(true)
+	s.waiting = append(s.waiting, client)
+
+	if !s.announcing && s.GM.Settings.Admission.Interval > 0 {
+		s.announcing = true
+		go s.announce(s.GM.Settings.Admission.Interval)
+	}
+
+	return position(client, len(s.waiting), len(s.waiting))
+}
+
+// The queue position event for a waiting client
+func position(client *Client, n int, waiting int) Event {
+	return NewDirectEvent(QueuePositionEvent, QueuePosition{Position: n, Waiting: waiting}, client.ID)
+}
+
+// Takes the client out of the login queue, false is returned
+// if it wasn't waiting
+func (s *Server) dequeue(client *Client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.waiting {
+		if c == client {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Frees the slot of a client that has left and lets in whoever
+// is next in the queue
+func (s *Server) release(client *Client) {
+	s.mu.Lock()
+	s.admitted--
+
+	if s.Closed() {
+		s.mu.Unlock()
+		return
+	}
+
+	var admitted, waiting []*Client
+
+	// Earlier clients go first, but a reserved slot skips ahead
+	// to the first priority client
+	for _, c := range s.waiting {
+		if s.admits(c, 0) {
+			s.admit(c)
+			admitted = append(admitted, c)
+			continue
+		}
+
+		waiting = append(waiting, c)
+	}
+
+	s.waiting = waiting
+	s.mu.Unlock()
+
+	// Everyone left waiting has moved up
+	if len(admitted) > 0 {
+		updatePositions(waiting)
+	}
+
+	for _, c := range admitted {
+		s.GM.Log.Infof("Client %s has left the login queue", c.ID)
+		s.GM.FireEvent(NewDirectEvent(ConnectedEvent, c, c.ID))
+	}
+}
+
+// Sends each waiting client its position until the queue is empty
+func (s *Server) announce(interval time.Duration) {
+	for {
+		select {
+		case <-time.After(interval):
+		case <-s.Shutdown:
+			return
+		}
+
+		s.mu.Lock()
+
+		if len(s.waiting) == 0 {
+			s.announcing = false
+			s.mu.Unlock()
+			return
+		}
+
+		waiting := append([]*Client(nil), s.waiting...)
+		s.mu.Unlock()
+
+		updatePositions(waiting)
+	}
+}
+
+// Offers each waiting client its position, a client that can't
+// take it right away misses the update rather than holding anyone up
+func updatePositions(waiting []*Client) {
+	for i, c := range waiting {
+		c.offer(position(c, i+1, len(waiting)))
+	}
+}
+
+// Removes everyone from the login queue, used when shutting down
+func (s *Server) drainQueue() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waiting := s.waiting
+	s.waiting = nil
+
+	for _, c := range waiting {
+		c.leave()
+	}
+
+	return waiting
+}
+
+// Marks whether the client is waiting in the login queue
+func (c *Client) setQueued(queued bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queued = queued
+}
+
+// Queued checks whether the client is waiting for a slot
+func (c *Client) Queued() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.queued
+}
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"context"
 	"flag"
+	"net"
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -32,9 +35,22 @@ type (
 		Components    *sync.Map
 		Subscribers   *sync.Map
 		Events        *sync.Map
+		Responders    *sync.Map
 		Server        *Server
+		HTTP          *http.Server
+		TCP           net.Listener
+		UDP           net.PacketConn
 		StreamManager *StreamManager
 		Log           *logrus.Logger
+
+		// Internal requests waiting on a response
+		pending *sync.Map
+		// Middleware wrapped around dispatch
+		middleware *middlewares
+		// Guards the listeners, they are set from their own goroutines
+		listenMu sync.Mutex
+		// Set once shutdown starts so late listeners are closed
+		stopping bool
 	}
 )
 
@@ -44,6 +60,10 @@ func NewGame() *GameManager {
 		Components:  new(sync.Map),
 		Subscribers: new(sync.Map),
 		Events:      new(sync.Map),
+		Responders:  new(sync.Map),
+		pending:     new(sync.Map),
+		middleware:  new(middlewares),
+		Settings:    DefaultSettings(),
 		Log:         NewLog(),
 		Environment: environment(),
 	}
@@ -114,6 +134,11 @@ func (GM *GameManager) Run() {
 	// Load Mongo
 	GM.CreateMongo()
 
+	// Use the built in authenticator if a secret has been given
+	if GM.Server.Auth == nil && GM.Settings.Auth.Secret != "" {
+		GM.Server.Auth = NewHMACAuthenticator(GM.Settings.Auth.Secret)
+	}
+
 	defer func() {
 		// Register Stream events
 		GM.StreamManager.Register()
@@ -129,19 +154,115 @@ func (GM *GameManager) Run() {
 	go GM.Server.Listen()
 }
 
+// Shutdown stops the game gracefully, new clients are turned away,
+// connected clients are disconnected once their pending events have
+// been written, channels are closed and finally the mongo session
+func (GM *GameManager) Shutdown(ctx context.Context) error {
+	GM.Log.Info("Shutting down...")
+
+	GM.listenMu.Lock()
+	GM.stopping = true
+	h, t, u := GM.HTTP, GM.TCP, GM.UDP
+	GM.listenMu.Unlock()
+
+	// Stop the listeners accepting anything new, connections
+	// that have already been accepted are left to the server
+	if h != nil {
+		if err := h.Shutdown(ctx); err != nil {
+			GM.Log.Error(err)
+		}
+	}
+
+	if t != nil {
+		if err := t.Close(); err != nil {
+			GM.Log.Error(err)
+		}
+	}
+
+	if u != nil {
+		if err := u.Close(); err != nil {
+			GM.Log.Error(err)
+		}
+	}
+
+	err := GM.Server.Close(ctx)
+
+	if GM.DB != nil {
+		GM.DB.Close()
+	}
+
+	return err
+}
+
+// Keeps a listener so that Shutdown can close it, returns false
+// when shutdown has already started and it shouldn't be served
+func (GM *GameManager) listen(keep func()) bool {
+	GM.listenMu.Lock()
+	defer GM.listenMu.Unlock()
+
+	if GM.stopping {
+		return false
+	}
+
+	keep()
+	return true
+}
+
 // CreateMongo attaches a new mongo wrapper to the game manager
 func (GM *GameManager) CreateMongo() {
 	GM.DB = NewMongo(GM)
 }
 
-// Connect adds a new client with the given connection and
-// identifier
-func (GM *GameManager) Connect(ws *websocket.Conn, id string) {
-	// Create the client
-	c := NewClient(&WebsocketConnection{Conn: ws}, id)
+// Connect adds a new client with the given connection for the
+// user, the client is given its own id so a user can connect more
+// than once. When the server has an authenticator the first message
+// has to be an auth event or the connection is refused
+func (GM *GameManager) Connect(ws *websocket.Conn, user string) error {
+	return GM.ConnectResume(ws, user, "")
+}
+
+// ConnectResume is Connect for a client asking to resume the session
+// of the token, an unknown token starts a new session
+func (GM *GameManager) ConnectResume(ws *websocket.Conn, user string, token string) error {
+	var ident *Identity
+
+	id, err := GM.Server.GenerateID()
+
+	if err != nil {
+		refuse(ws, websocket.CloseInternalServerErr, "unable to assign client id")
+		return err
+	}
+
+	codec := GM.Server.codec(ws.Subprotocol())
+	compression := GM.Settings.Compression
+
+	if compression.Enabled {
+		if err := ws.SetCompressionLevel(compression.Level); err != nil {
+			GM.Log.Error(err)
+		}
+	}
+
+	if GM.Server.Auth != nil {
+		if ident, err = GM.Server.authenticateMessage(ws, codec); err != nil {
+			refuse(ws, websocket.ClosePolicyViolation, ErrUnauthorized.Error())
+			return err
+		}
+	}
 
-	// Register it on the server
-	GM.Server.Register <- c
+	// Create the client
+	c := GM.Server.newClient(&WebsocketConnection{Conn: ws, Codec: codec, Compression: compression}, id)
+	c.UserID = user
+	c.identify(ident)
+	c.resumeWith = token
+
+	// Register it on the server, unless it has stopped listening
+	select {
+	case GM.Server.Register <- c:
+		return nil
+	case <-GM.Server.Shutdown:
+		refuse(ws, websocket.CloseGoingAway, "")
+		return ErrServerClosed
+	}
 }
 
 // PutTrait binds an existing trait to a client
@@ -155,19 +276,16 @@ func (GM *GameManager) RemoveTrait(n string, c *Client) {
 	c.RemoveTrait(n)
 }
 
-// RegisterHandler registers a new event handler
-func (GM *GameManager) RegisterHandler(n string, h EventHandler) {
-	var handlers []EventHandler
-
-	reg, ok := GM.Subscribers.Load(n)
-
-	if ok {
-		handlers = reg.([]EventHandler)
-	}
+// RegisterHandler registers a new event handler with the default priority
+func (GM *GameManager) RegisterHandler(n string, h EventHandler) *Subscription {
+	return GM.RegisterPriorityHandler(n, DefaultPriority, h)
+}
 
-	// append the new handler
-	handlers = append(handlers, h)
-	GM.Subscribers.Store(n, handlers)
+// RegisterPriorityHandler registers a new event handler, handlers with
+// a higher priority are called first and can return Stop to keep the
+// event from the rest
+func (GM *GameManager) RegisterPriorityHandler(n string, p int, h EventHandler) *Subscription {
+	return subscribe(GM.Subscribers, n, p, h)
 }
 
 // Event registers a new event definition, all events
@@ -212,14 +330,76 @@ func (GM *GameManager) RegisterComponents() {
 	GM.Log.Info("Finished registering components...")
 }
 
-// FireEvent fires the event using the rules registered in the
-// associative definition
+// FireEvent passes the event through any middleware and then fires
+// it using the rules registered in the associative definition
 func (GM *GameManager) FireEvent(e Event) {
+	defer GM.recoverDispatch(e)
+	GM.chain(GM.dispatch)(e)
+}
+
+// Sends the event to its channels and any responder, this is the
+// end of the middleware chain
+func (GM *GameManager) dispatch(e Event) {
+	definition, ok := GM.definition(e)
+
+	if !ok {
+		return
+	}
+
+	e.unreliable = definition.Unreliable
+
+	// Keys are scoped to the event so different events that use
+	// the same key don't replace each other
+	if definition.Coalesce != nil {
+		if key := definition.Coalesce(e); key != "" {
+			e.coalesce = e.Name + "\x00" + key
+		}
+	}
+
+	go GM.Server.SendToChannels(e, definition)
+	GM.request(e)
+}
+
+// Hands requests to the responder registered for the event
+func (GM *GameManager) request(e Event) {
+	if e.CorrelationID == "" {
+		return
+	}
+
+	if h, ok := GM.Responders.Load(e.Name); ok {
+		go GM.respond(e, h.(RequestHandler))
+	}
+}
+
+// Fires the event and waits for every channel to receive it
+func (GM *GameManager) fireSync(e Event) {
+	defer GM.recoverDispatch(e)
+	GM.chain(func(e Event) {
+		if definition, ok := GM.definition(e); ok {
+			GM.Server.SendToChannels(e, definition)
+		}
+	})(e)
+}
+
+// Recovers from a panic in middleware so it can't take down
+// whatever fired the event
+func (GM *GameManager) recoverDispatch(e Event) {
+	if r := recover(); r != nil {
+		GM.Log.Error(r)
+		GM.Reject(e, HandlerPanicError, "the server failed to handle the event")
+	}
+}
+
+// Finds the definition for an event and validates the event
+// against it, false is returned if the event can't be sent
+func (GM *GameManager) definition(e Event) (definition EventDefinition, ok bool) {
 
 	// Panic recovery
 	defer func() {
 		if r := recover(); r != nil {
 			GM.Log.Error(r)
+			GM.Reject(e, HandlerPanicError, "the server failed to handle the event")
+			ok = false
 		}
 	}()
 
@@ -227,16 +407,24 @@ func (GM *GameManager) FireEvent(e Event) {
 
 	if !ok {
 		GM.Log.Errorf("Unable to locate a triggered event %s", e.Name)
+		GM.Reject(e, UnknownEventError, "unknown event "+e.Name)
 		return
 	}
 
-	definition := def.(EventDefinition)
+	definition = def.(EventDefinition)
+
+	if !definition.Allows(e.Origin) {
+		GM.Log.Warningf("Client %s tried to fire %s which it is not allowed to", e.ClientID, e.Name)
+		GM.Reject(e, ForbiddenError, "event "+e.Name+" can not be fired by clients")
+		return definition, false
+	}
 
 	if err := definition.Validate(e.Data); err != nil {
 		GM.Log.Error("Unable to send message as it does not adhere to schema")
 		GM.Log.Error(err)
-		return
+		GM.invalid(e, err)
+		return definition, false
 	}
 
-	go GM.Server.SendToChannels(e, definition)
+	return definition, true
 }
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// AuthEvent constant value for the first message auth event
+	AuthEvent = "auth"
+)
+
+var (
+	// ErrUnauthorized is returned when a token can't be verified
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrTokenExpired is returned when a token is past its expiry
+	ErrTokenExpired = errors.New("token has expired")
+)
+
+type (
+	// Authenticator verifies the token a client connects with
+	// and resolves who the client is
+	Authenticator interface {
+		Authenticate(token string) (*Identity, error)
+	}
+
+	// Identity is the verified result of authentication
+	Identity struct {
+		Subject string
+		Claims  Claims
+	}
+
+	// Claims are the verified details about a client
+	Claims map[string]interface{}
+
+	// HMACAuthenticator verifies tokens signed with a shared secret,
+	// tokens are the base64 encoded claims followed by their
+	// HMAC-SHA256 signature, separated by a dot
+	HMACAuthenticator struct {
+		Secret []byte
+	}
+
+	// The data expected with the first message auth event
+	authSchema struct {
+		Token string `json:"token"`
+	}
+)
+
+// NewHMACAuthenticator creates an authenticator using the given secret
+func NewHMACAuthenticator(secret string) *HMACAuthenticator {
+	return &HMACAuthenticator{Secret: []byte(secret)}
+}
+
+// SignToken creates a token for the given claims, the subject is
+// expected in "sub" and an optional unix expiry in "exp"
+func SignToken(secret string, c Claims) (string, error) {
+	payload, err := json.Marshal(c)
+
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString(payload)
+	return enc + "." + sign(secret, enc), nil
+}
+
+// Signs the encoded payload
+func sign(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticate verifies the signature and expiry of the token
+func (a *HMACAuthenticator) Authenticate(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 2 {
+		return nil, ErrUnauthorized
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(sign(string(a.Secret), parts[0]))) {
+		return nil, ErrUnauthorized
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	var c Claims
+
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	if exp, ok := c["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, ErrTokenExpired
+	}
+
+	sub := c.String("sub")
+
+	if sub == "" {
+		return nil, ErrUnauthorized
+	}
+
+	return &Identity{Subject: sub, Claims: c}, nil
+}
+
+// String returns a claim as a string, or empty if it isn't one
+func (c Claims) String(n string) string {
+	v, _ := c[n].(string)
+	return v
+}
+
+// Bool returns a claim as a bool, or false if it isn't one
+func (c Claims) Bool(n string) bool {
+	v, _ := c[n].(bool)
+	return v
+}
+
+// Finds the token given in the handshake, browsers can't set
+// headers on websockets so the query string is also checked
+func handshakeToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// Waits for the first message from the client, which has to be
+// an auth event carrying the token
+func (s *Server) authenticateMessage(ws *websocket.Conn, codec Codec) (*Identity, error) {
+	ws.SetReadDeadline(deadline(s.GM.Settings.Auth.Timeout))
+	defer ws.SetReadDeadline(time.Time{})
+
+	_, msg, err := ws.ReadMessage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authenticateEvent(msg, codec)
+}
+
+// Authenticates the token in an encoded auth event
+func (s *Server) authenticateEvent(msg []byte, codec Codec) (*Identity, error) {
+	var e struct {
+		Name string     `json:"name"`
+		Data authSchema `json:"data"`
+	}
+
+	if err := codec.Unmarshal(msg, &e); err != nil || e.Name != AuthEvent {
+		return nil, ErrUnauthorized
+	}
+
+	return s.Auth.Authenticate(e.Data.Token)
+}
+
+// Sets the verified identity on the client
+func (c *Client) identify(i *Identity) {
+	if i == nil {
+		return
+	}
+
+	c.UserID = i.Subject
+	c.Claims = i.Claims
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"bytes"
+	"time"
+)
+
+type (
+	// Events gathered from the queue to be written together, only
+	// the newest event for each coalescing key is kept
+	outbox struct {
+		events []Event
+		keys   map[string]int
+		size   int
+	}
+)
+
+// Gathers the event along with the events waiting behind it. When
+// batching is enabled this waits for the batch to fill or for the
+// interval to pass, false is returned if the queue was closed
+func (c *Client) gather(first Event, b BatchSettings) ([]Event, bool) {
+	ob := &outbox{keys: map[string]int{}}
+	ob.add(first)
+
+	// Without batching only what is already waiting is coalesced
+	if b.Size <= 1 {
+		for i := len(c.Send); i > 0; i-- {
+			e, ok := <-c.Send
+
+			if !ok {
+				return ob.flush(), false
+			}
+
+			ob.add(e)
+		}
+
+		return ob.flush(), true
+	}
+
+	var interval <-chan time.Time
+
+	if b.Interval > 0 {
+		timer := time.NewTimer(b.Interval)
+		defer timer.Stop()
+		interval = timer.C
+	}
+
+	for ob.size < b.Size {
+		// A zero interval flushes as soon as the queue is empty
+		if interval == nil {
+			select {
+			case e, ok := <-c.Send:
+				if !ok {
+					return ob.flush(), false
+				}
+
+				ob.add(e)
+				continue
+			default:
+				return ob.flush(), true
+			}
+		}
+
+		select {
+		case e, ok := <-c.Send:
+			if !ok {
+				return ob.flush(), false
+			}
+
+			ob.add(e)
+		case <-interval:
+			return ob.flush(), true
+		}
+	}
+
+	return ob.flush(), true
+}
+
+// Adds the event, replacing an older event with the same key
+func (ob *outbox) add(e Event) {
+	if e.coalesce != "" {
+		if _, ok := ob.keys[e.coalesce]; ok {
+			ob.size--
+		}
+
+		ob.keys[e.coalesce] = len(ob.events)
+	}
+
+	ob.events = append(ob.events, e)
+	ob.size++
+}
+
+// The events to write in the order they were queued, events
+// replaced by a newer one with the same key are skipped
+func (ob *outbox) flush() []Event {
+	events := make([]Event, 0, ob.size)
+
+	for i, e := range ob.events {
+		if e.coalesce != "" && ob.keys[e.coalesce] != i {
+			continue
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// Encodes the events as a single array frame, each event reuses
+// its shared frame where the codec allows it
+func encodeBatch(events []Event, c Codec) ([]byte, error) {
+	var buf bytes.Buffer
+	_, isJSON := c.(JSONCodec)
+
+	switch {
+	case isJSON:
+		buf.WriteByte('[')
+	case c.Name() == MsgPackProtocol:
+		encodeMsgPackLength(&buf, len(events), 0x90, 15, 0, 0xdc, 0xdd)
+	default:
+		return c.Marshal(events)
+	}
+
+	for i, e := range events {
+		data, err := e.encode(c)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if isJSON && i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(data)
+	}
+
+	if isJSON {
+		buf.WriteByte(']')
+	}
+
+	return buf.Bytes(), nil
+}
@@ -72,16 +72,29 @@ type (
 func SendToClients(GM *GameManager, clients *sync.Map, e Event) {
 	// If the message is a broadcast send it to everyone
 	if e.Broadcast {
+		e = e.shared()
+
 		clients.Range(func(k, v interface{}) bool {
 			client := v.(*Client)
 
-			client.Send <- e
+			client.Push(e)
 			return true
 		})
 
 		return
 	}
 
+	// Only the users sessions that are on this channel are sent to
+	if e.ClientID == "" && e.UserID != "" {
+		for _, client := range GM.Server.FindUser(e.UserID) {
+			if _, ok := clients.Load(client.ID); ok {
+				client.Push(e)
+			}
+		}
+
+		return
+	}
+
 	if e.ClientID == "" {
 		GM.Log.Errorf("Direct event sent with no client id: %+v", e)
 		return
@@ -95,20 +108,12 @@ func SendToClients(GM *GameManager, clients *sync.Map, e Event) {
 	}
 
 	client := cl.(*Client)
-	client.Send <- e
+	client.Push(e)
 }
 
 // SendToTraits sends messages to traits
 func SendToTraits(client *Client, e Event) {
-	subs, ok := client.Subscribers.Load(e.Name)
-
-	if ok {
-		subscribers := subs.([]EventHandler)
-
-		for _, sub := range subscribers {
-			sub(e)
-		}
-	}
+	propagate(client.Subscribers, e)
 }
 
 // SetGM sets the GameManager instance
@@ -188,6 +193,16 @@ func (ch *StreamChannel) Send(e Event, d EventDefinition) {
 
 // Send is the send policy for direct channels
 func (ch *DirectChannel) Send(e Event, d EventDefinition) {
+	// Events for a user go to each of their sessions
+	if e.ClientID == "" && e.UserID != "" {
+		for _, client := range ch.GM.Server.FindUser(e.UserID) {
+			SendToTraits(client, e)
+			client.Push(e)
+		}
+
+		return
+	}
+
 	if e.ClientID == "" {
 		ch.GM.Log.Errorf("Direct event sent with no client id: %+v", e)
 		return
@@ -203,7 +218,7 @@ func (ch *DirectChannel) Send(e Event, d EventDefinition) {
 	// Before sending directly to the client we should send this event
 	// to any subscribers the client may have through its instanced components
 	SendToTraits(client, e)
-	client.Send <- e
+	client.Push(e)
 }
 
 // Send method for the internal channel
@@ -212,21 +227,13 @@ func (ch *InternalChannel) Send(e Event, d EventDefinition) {
 	defer func() {
 		if r := recover(); r != nil {
 			ch.GM.Log.Error(r)
+			ch.GM.Reject(e, HandlerPanicError, "the server failed to handle the event")
 		}
 	}()
 
-	subs, ok := ch.GM.Subscribers.Load(e.Name)
-
-	if !ok {
+	// Fire all the things, until one of them stops the event
+	if !propagate(ch.GM.Subscribers, e) {
 		ch.GM.Log.Warningf("Internal event called with no active subscribers: %s", e.Name)
-		return
-	}
-
-	subscribers := subs.([]EventHandler)
-
-	// Fire all the things
-	for _, sub := range subscribers {
-		sub(e)
 	}
 }
 
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// JSONProtocol is the subprotocol name for the json codec
+	JSONProtocol = "json"
+
+	// MsgPackProtocol is the subprotocol name for the msgpack codec
+	MsgPackProtocol = "msgpack"
+)
+
+type (
+	// Codec encodes events for the wire, connections pick a codec
+	// using the websocket subprotocol the client asks for
+	Codec interface {
+		// Name is the subprotocol the codec is negotiated with
+		Name() string
+		// Binary is true when the codec writes binary frames
+		Binary() bool
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	// JSONCodec is the default codec
+	JSONCodec struct{}
+
+	// MsgPackCodec is a compact binary codec using the MessagePack format
+	MsgPackCodec struct{}
+
+	// Frames an event has already been encoded to, shared between
+	// the copies of a broadcast event so it is only encoded once
+	// per codec rather than once per client
+	frameCache struct {
+		frames sync.Map
+	}
+
+	// A single encoded frame
+	frame struct {
+		once sync.Once
+		data []byte
+		err  error
+	}
+)
+
+// Name returns the subprotocol name
+func (JSONCodec) Name() string { return JSONProtocol }
+
+// Binary json is sent as text
+func (JSONCodec) Binary() bool { return false }
+
+// Marshal encodes to json
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes from json
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name returns the subprotocol name
+func (MsgPackCodec) Name() string { return MsgPackProtocol }
+
+// Binary msgpack is sent as binary
+func (MsgPackCodec) Binary() bool { return true }
+
+// Marshal encodes to msgpack, values are first reduced to their
+// json form so field names and tags match the json codec
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := encodeMsgPack(&buf, generic); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes from msgpack
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	generic, err := decodeMsgPack(bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(generic)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// DefaultCodecs returns the codecs offered to clients in order of
+// preference, json is used when the client doesn't ask for one
+func DefaultCodecs() []Codec {
+	return []Codec{JSONCodec{}, MsgPackCodec{}}
+}
+
+// Encodes the event with the codec, reusing the frame if this
+// event has already been encoded with it
+func (e Event) encode(c Codec) ([]byte, error) {
+	if e.frames == nil {
+		return c.Marshal(e)
+	}
+
+	f, _ := e.frames.frames.LoadOrStore(c.Name(), &frame{})
+	fr := f.(*frame)
+
+	fr.once.Do(func() {
+		fr.data, fr.err = c.Marshal(e)
+	})
+
+	return fr.data, fr.err
+}
+
+// Marks the event as shared between clients so it is encoded once
+func (e Event) shared() Event {
+	if e.frames == nil {
+		e.frames = &frameCache{}
+	}
+
+	return e
+}
+
+// Finds the codec for a negotiated subprotocol
+func (s *Server) codec(name string) Codec {
+	for _, c := range s.Codecs {
+		if c.Name() == name {
+			return c
+		}
+	}
+
+	return JSONCodec{}
+}
+
+// The subprotocols clients can ask for
+func (s *Server) subprotocols() []string {
+	names := make([]string, len(s.Codecs))
+
+	for i, c := range s.Codecs {
+		names[i] = c.Name()
+	}
+
+	return names
+}
+
+// The websocket frame type a codec writes
+func messageType(c Codec) int {
+	if c.Binary() {
+		return websocket.BinaryMessage
+	}
+
+	return websocket.TextMessage
+}
@@ -100,9 +100,50 @@ func (c *Component) Event(n string, ch []string) {
 	c.GM.Event(EventDefinition{Name: n, Channels: ch})
 }
 
+// ClientEvent registers an event that clients are allowed to fire
+func (c *Component) ClientEvent(n string, ch []string) {
+	c.GM.Event(EventDefinition{Name: n, Channels: ch, Origins: []string{ClientOrigin, InternalOrigin}})
+}
+
+// UnreliableEvent registers an event that is sent over udp to
+// clients that have bound it
+func (c *Component) UnreliableEvent(n string, ch []string) {
+	c.GM.Event(EventDefinition{Name: n, Channels: ch, Unreliable: true})
+}
+
 // Handler proxy method to register a new event handler
-func (c *Component) Handler(n string, h EventHandler) {
-	c.GM.RegisterHandler(n, h)
+func (c *Component) Handler(n string, h EventHandler) *Subscription {
+	return c.GM.RegisterHandler(n, h)
+}
+
+// PriorityHandler proxy method to register an event handler with a priority
+func (c *Component) PriorityHandler(n string, p int, h EventHandler) *Subscription {
+	return c.GM.RegisterPriorityHandler(n, p, h)
+}
+
+// Handle proxy method to register a typed event handler
+func (c *Component) Handle(n string, fn interface{}) *Subscription {
+	return c.GM.Handle(n, fn)
+}
+
+// Use proxy method to add middleware around every event
+func (c *Component) Use(mw ...Middleware) {
+	c.GM.Use(mw...)
+}
+
+// UseChannel proxy method to add middleware around a channel
+func (c *Component) UseChannel(n string, mw ...ChannelMiddleware) {
+	c.GM.UseChannel(n, mw...)
+}
+
+// Respond proxy method to register a responder for requests
+func (c *Component) Respond(n string, h RequestHandler) {
+	c.GM.Respond(n, h)
+}
+
+// Request proxy method to ask another component a question
+func (c *Component) Request(n string, d interface{}) (interface{}, error) {
+	return c.GM.Request(n, d)
 }
 
 // Fire is a proxy method for the managers Fire event
@@ -115,6 +156,12 @@ func (c *Component) FireTo(n string, cl string, d interface{}) {
 	c.GM.FireEvent(NewDirectEvent(n, d, cl))
 }
 
+// FireToUser is a proxy method to fire a direct event to
+// every session of a user
+func (c *Component) FireToUser(n string, u string, d interface{}) {
+	c.GM.FireEvent(NewUserEvent(n, d, u))
+}
+
 // ConnectTo is a proxy method for the servers connect to channel method
 func (c *Component) ConnectTo(n string, client *Client) {
 	c.GM.Server.ConnectTo(n, client)
@@ -1,13 +1,16 @@
 package engine
 
 import (
+	"compress/flate"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -46,6 +49,196 @@ type (
 		// Config entries are scanned and config files are loaded
 		// from them
 		Config []string `yaml:"config"`
+		// Server controls the built in http endpoint
+		Server ServerSettings `yaml:"server"`
+		// TLS controls the certificate the listeners are served with
+		TLS TLSSettings `yaml:"tls"`
+		// Heartbeat controls how dead connections are detected
+		Heartbeat HeartbeatSettings `yaml:"heartbeat"`
+		// Queue controls each clients outbound queue
+		Queue QueueSettings `yaml:"queue"`
+		// Resume controls whether dropped clients can resume
+		Resume ResumeSettings `yaml:"resume"`
+		// Auth controls the built in token authentication
+		Auth AuthSettings `yaml:"auth"`
+		// RateLimit limits how quickly each client can fire events
+		RateLimit RateLimitSettings `yaml:"rateLimit"`
+		// RPC controls requests made with correlation ids
+		RPC RPCSettings `yaml:"rpc"`
+		// TCP controls the framed tcp listener
+		TCP TCPSettings `yaml:"tcp"`
+		// SSE controls the server sent events fallback
+		SSE SSESettings `yaml:"sse"`
+		// UDP controls the unreliable side channel
+		UDP UDPSettings `yaml:"udp"`
+		// Batch controls how outbound events are grouped into frames
+		Batch BatchSettings `yaml:"batch"`
+		// Compression controls permessage-deflate for websockets
+		Compression CompressionSettings `yaml:"compression"`
+		// Admission caps the number of connected clients
+		Admission AdmissionSettings `yaml:"admission"`
+		// Users controls sessions of the same authenticated user
+		Users UserSettings `yaml:"users"`
+		// Metadata controls what is shared about each client
+		Metadata MetadataSettings `yaml:"metadata"`
+	}
+
+	// ServerSettings describe where the built in websocket
+	// endpoint listens for new connections
+	ServerSettings struct {
+		Address string `yaml:"address"`
+		Path    string `yaml:"path"`
+		// Origins browsers may connect from, exact origins or
+		// patterns like https://*.example.com. When empty the
+		// origin has to match the host. A single star allows any
+		// origin but those aren't sent credentials
+		Origins []string `yaml:"origins"`
+	}
+
+	// TLSSettings serve the http and tcp listeners over tls when
+	// a certificate is given
+	TLSSettings struct {
+		CertFile string `yaml:"certFile"`
+		KeyFile  string `yaml:"keyFile"`
+		// MinVersion is the oldest version accepted, such as 1.2
+		MinVersion string `yaml:"minVersion"`
+		// Reload is how often the files are checked for a renewed
+		// certificate, zero disables reloading
+		Reload time.Duration `yaml:"reload"`
+	}
+
+	// HeartbeatSettings control pings and timeouts for connections,
+	// a zero value disables the related check
+	HeartbeatSettings struct {
+		// PingInterval is how often the server pings the client
+		PingInterval time.Duration `yaml:"pingInterval"`
+		// PongWait is how long the server waits to hear anything
+		// back from the client, this should be above PingInterval
+		PongWait time.Duration `yaml:"pongWait"`
+		// WriteTimeout is the time allowed to write a single message
+		WriteTimeout time.Duration `yaml:"writeTimeout"`
+		// MaxIdle disconnects clients that haven't sent an event
+		// for this long, even if they still answer pings
+		MaxIdle time.Duration `yaml:"maxIdle"`
+	}
+
+	// QueueSettings control the outbound queue of each client
+	QueueSettings struct {
+		// Size is the number of events that can wait to be written
+		Size int `yaml:"size"`
+		// Policy decides what happens when the queue is full, one of
+		// block, drop-oldest, drop-newest or disconnect
+		Policy string `yaml:"policy"`
+	}
+
+	// ResumeSettings control session resumption, clients that drop
+	// can reconnect within the grace period and carry on where they
+	// left off. A zero grace period disables resumption
+	ResumeSettings struct {
+		// Grace is how long a session is kept after its connection drops
+		Grace time.Duration `yaml:"grace"`
+		// Buffer is the number of direct events held for replay
+		Buffer int `yaml:"buffer"`
+	}
+
+	// AuthSettings configure the built in HMAC authenticator, which
+	// is used when a secret is set and no other authenticator is
+	AuthSettings struct {
+		// Secret is the key tokens are signed with
+		Secret string `yaml:"secret"`
+		// Timeout is how long a client has to send its auth event
+		Timeout time.Duration `yaml:"timeout"`
+	}
+
+	// RateLimitSettings are the limits applied to all events from
+	// a client, event definitions can add their own limits on top
+	RateLimitSettings struct {
+		RateLimit `yaml:",inline"`
+		// Action is taken when a client goes over, one of
+		// drop, warn or disconnect
+		Action string `yaml:"action"`
+	}
+
+	// RPCSettings control request and response events
+	RPCSettings struct {
+		// Timeout is how long a responder has to answer
+		Timeout time.Duration `yaml:"timeout"`
+	}
+
+	// TCPSettings describe the framed tcp listener, it is only
+	// started when an address is given
+	TCPSettings struct {
+		Address string `yaml:"address"`
+		// Codec is the name of the codec used for every tcp client
+		Codec string `yaml:"codec"`
+		// MaxFrame is the largest frame in bytes a client can send
+		MaxFrame int `yaml:"maxFrame"`
+	}
+
+	// SSESettings describe the server sent events fallback, it is
+	// served next to the websocket endpoint when a path is given
+	SSESettings struct {
+		Path string `yaml:"path"`
+		// MaxBody is the largest event in bytes a client can post
+		MaxBody int64 `yaml:"maxBody"`
+	}
+
+	// UDPSettings describe the unreliable side channel, it is only
+	// started when an address is given
+	UDPSettings struct {
+		Address string `yaml:"address"`
+		// Codec is the name of the codec used for packets
+		Codec string `yaml:"codec"`
+		// MaxPacket is the largest packet in bytes that is read
+		MaxPacket int `yaml:"maxPacket"`
+	}
+
+	// BatchSettings group outbound websocket events into array
+	// frames, a size of one or less disables batching
+	BatchSettings struct {
+		// Size is the most events written in a single frame
+		Size int `yaml:"size"`
+		// Interval is how long to wait for a batch to fill, when
+		// zero the batch is written once the queue is empty
+		Interval time.Duration `yaml:"interval"`
+	}
+
+	// CompressionSettings control permessage-deflate, it is only used
+	// when the client also asks for it during the handshake
+	CompressionSettings struct {
+		Enabled bool `yaml:"enabled"`
+		// Level is the flate level, from -2 for huffman only to 9
+		Level int `yaml:"level"`
+		// MinSize is the smallest frame in bytes that is compressed,
+		// smaller frames aren't worth the cost
+		MinSize int `yaml:"minSize"`
+	}
+
+	// AdmissionSettings cap how many clients can be connected at once,
+	// clients over the cap wait in a login queue for a free slot
+	AdmissionSettings struct {
+		// MaxClients is the cap, zero allows any number of clients
+		MaxClients int `yaml:"maxClients"`
+		// Reserved slots can only be taken by priority clients
+		Reserved int `yaml:"reserved"`
+		// PriorityClaim is the auth claim that marks a priority client
+		PriorityClaim string `yaml:"priorityClaim"`
+		// Interval is how often waiting clients are sent their position
+		Interval time.Duration `yaml:"interval"`
+	}
+
+	// UserSettings control how many sessions a user can have
+	UserSettings struct {
+		// Duplicates is the policy for a user connecting again, one
+		// of allow, kick or reject
+		Duplicates string `yaml:"duplicates"`
+	}
+
+	// MetadataSettings control client metadata
+	MetadataSettings struct {
+		// Expose lists the keys sent to the client along with
+		// the rest of its details in the connected event
+		Expose []string `yaml:"expose"`
 	}
 )
 
@@ -57,6 +250,60 @@ func NewConfig(GM *GameManager) *ConfigManager {
 	}
 }
 
+// DefaultSettings creates the settings used when nothing
+// has been provided by the standard config
+func DefaultSettings() *GorgeSettings {
+	st := &GorgeSettings{}
+	st.Server = ServerSettings{Address: ":8080", Path: "/ws"}
+	st.TLS = TLSSettings{MinVersion: "1.2", Reload: time.Minute}
+	st.Heartbeat = HeartbeatSettings{
+		PingInterval: 50 * time.Second,
+		PongWait:     60 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	st.Queue = QueueSettings{Size: 256, Policy: DisconnectPolicy}
+	st.Resume = ResumeSettings{Buffer: 128}
+	st.Auth = AuthSettings{Timeout: 10 * time.Second}
+	st.RateLimit = RateLimitSettings{RateLimit: RateLimit{Rate: 100, Burst: 200}, Action: WarnAction}
+	st.RPC = RPCSettings{Timeout: 10 * time.Second}
+	st.TCP = TCPSettings{Codec: JSONProtocol, MaxFrame: 1 << 20}
+	st.SSE = SSESettings{Path: "/sse", MaxBody: 1 << 20}
+	st.UDP = UDPSettings{Codec: MsgPackProtocol, MaxPacket: 1400}
+	st.Compression = CompressionSettings{Level: flate.BestSpeed, MinSize: 1024}
+	st.Admission = AdmissionSettings{PriorityClaim: "priority", Interval: 5 * time.Second}
+	st.Users = UserSettings{Duplicates: AllowDuplicates}
+
+	return st
+}
+
+// Validate checks the settings that name a policy or action, unknown
+// values would otherwise silently fall back to a default
+func (st *GorgeSettings) Validate() error {
+	switch st.Queue.Policy {
+	case BlockPolicy:
+	case DropOldestPolicy, DropNewestPolicy, DisconnectPolicy:
+		if st.Queue.Size < 1 {
+			return fmt.Errorf("queue size must be at least 1 for the %s policy", st.Queue.Policy)
+		}
+	default:
+		return fmt.Errorf("unknown queue policy %q", st.Queue.Policy)
+	}
+
+	switch st.RateLimit.Action {
+	case DropAction, WarnAction, DisconnectAction:
+	default:
+		return fmt.Errorf("unknown rate limit action %q", st.RateLimit.Action)
+	}
+
+	switch st.Users.Duplicates {
+	case AllowDuplicates, KickDuplicates, RejectDuplicates:
+	default:
+		return fmt.Errorf("unknown duplicate session policy %q", st.Users.Duplicates)
+	}
+
+	return nil
+}
+
 // WriteConfig writes to the given config file
 func WriteConfig(i interface{}, d string) error {
 	// Convert to yaml
@@ -81,16 +328,22 @@ func (c *ConfigManager) AddTarget(n ...string) {
 func (c *ConfigManager) LoadStandard() {
 	c.Fetch(StandardConfig)
 
-	// Convert it
-	var st GorgeSettings
+	// Convert it, starting from the defaults so that
+	// anything missing from the file is still set
+	st := DefaultSettings()
 
-	if err := c.ConvertYaml("gorge", &st); err != nil {
+	if err := c.ConvertYaml("gorge", st); err != nil {
 		c.gm.Log.Error("Unable to convert standard config: " + err.Error())
 		return
 	}
 
+	if err := st.Validate(); err != nil {
+		c.gm.Log.Error("Invalid standard config: " + err.Error())
+		return
+	}
+
 	// Otherwise load the settings into the GM
-	c.gm.Settings = &st
+	c.gm.Settings = st
 
 	// If we have values for config, use them
 	if len(st.Config) > 0 {
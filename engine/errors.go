@@ -0,0 +1,80 @@
+package engine
+
+const (
+	// ErrorEvent constant value for the event sent to clients
+	// when their event is rejected
+	ErrorEvent = "error"
+
+	// UnknownEventError is used when no event has been registered
+	// with the name the client gave
+	UnknownEventError = "unknown_event"
+
+	// ValidationError is used when the event data fails validation
+	ValidationError = "validation_failed"
+
+	// ForbiddenError is used when a client fires an event it isn't
+	// allowed to
+	ForbiddenError = "forbidden"
+
+	// RateLimitedError is used when a client fires events too quickly
+	RateLimitedError = "rate_limited"
+
+	// HandlerPanicError is used when something panics while the
+	// event is being handled
+	HandlerPanicError = "handler_panic"
+
+	// RequestFailedError is used when a responder returns an error
+	RequestFailedError = "request_failed"
+
+	// TimeoutError is used when a responder takes too long
+	TimeoutError = "timeout"
+
+	// DuplicateSessionError is used when a session is ended or
+	// turned away because the user has signed in elsewhere
+	DuplicateSessionError = "duplicate_session"
+)
+
+type (
+	// ErrorPayload is the data of an error event
+	ErrorPayload struct {
+		EventID       string `json:"eventId"`
+		CorrelationID string `json:"correlationId,omitempty"`
+		Code          string `json:"code"`
+		Message       string `json:"message"`
+		// Fields lists the fields that failed schema validation
+		Fields []FieldError `json:"fields,omitempty"`
+	}
+)
+
+// Reject tells the client that fired the event why it was rejected,
+// events that didn't come from a client are ignored
+func (GM *GameManager) Reject(e Event, code string, msg string) {
+	GM.reject(e, ErrorPayload{Code: code, Message: msg})
+}
+
+// Rejects an event that failed validation, schema errors are
+// broken down by field so the client can point at what is wrong
+func (GM *GameManager) invalid(e Event, err error) {
+	payload := ErrorPayload{Code: ValidationError, Message: err.Error()}
+
+	if se, ok := err.(*SchemaError); ok {
+		payload.Fields = se.Fields
+	}
+
+	GM.reject(e, payload)
+}
+
+// Sends the error back to the client that fired the event
+func (GM *GameManager) reject(e Event, payload ErrorPayload) {
+	if e.Origin != ClientOrigin || e.ClientID == "" {
+		return
+	}
+
+	payload.EventID = e.ID
+	payload.CorrelationID = e.CorrelationID
+	ev := NewDirectEvent(ErrorEvent, payload, e.ClientID)
+
+	// Requests are matched up to their error the same way as a reply
+	ev.CorrelationID = e.CorrelationID
+	GM.FireEvent(ev)
+}
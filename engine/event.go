@@ -24,7 +24,19 @@ type (
 		Broadcast bool        `json:"broadcast"`
 		Origin    string      `json:"origin"`
 		ClientID  string      `json:"clientId"`
-		CreatedAt time.Time   `json:"createdAt"`
+		// UserID targets every session of a user when no
+		// client id is given
+		UserID    string    `json:"userId,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+		// CorrelationID marks the event as a request, the reply
+		// is sent back with the same id
+		CorrelationID string `json:"correlationId,omitempty"`
+
+		// Set when the event is shared between clients
+		frames *frameCache
+		// Copied from the definition when the event is fired
+		unreliable bool
+		coalesce   string
 	}
 
 	// EventDefinition stores the definition of an event
@@ -34,6 +46,19 @@ type (
 		Description string
 		Channels    []string
 		Validator   EventValidator
+		// Origins that may fire the event, when empty only
+		// the server itself can fire it
+		Origins []string
+		// RateLimit limits how often each client can fire the event
+		RateLimit *RateLimit
+		// Unreliable events are sent over udp to clients that have
+		// bound it, which may drop or reorder them
+		Unreliable bool
+		// Coalesce gives the key used to drop queued events that
+		// have been superseded, only the newest event for a key is
+		// written. Keys only apply within the event and an empty
+		// key means the event is always written
+		Coalesce func(e Event) string
 	}
 
 	// EventValidator allows the attaching of a validator
@@ -62,6 +87,25 @@ func (e EventDefinition) Validate(p interface{}) error {
 	return nil
 }
 
+// Allows checks whether the event can be fired from the given origin
+func (e EventDefinition) Allows(origin string) bool {
+	if origin == "" {
+		origin = InternalOrigin
+	}
+
+	if len(e.Origins) == 0 {
+		return origin == InternalOrigin
+	}
+
+	for _, o := range e.Origins {
+		if o == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
 // NewEvent creates a new event
 func NewEvent(name string, data interface{}) Event {
 	id, _ := shortid.Generate()
@@ -83,6 +127,13 @@ func NewDirectEvent(n string, d interface{}, c string) Event {
 	return ev
 }
 
+// NewUserEvent creates a direct event for every session of a user
+func NewUserEvent(n string, d interface{}, u string) Event {
+	ev := NewEvent(n, d)
+	ev.UserID = u
+	return ev
+}
+
 // NewEventValidator creates a new event validator given a file name
 func NewEventValidator(file string, h Validation) EventValidator {
 	rs, err := ioutil.ReadFile(file)
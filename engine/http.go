@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/teris-io/shortid"
+)
+
+type (
+	// IDGenerator is used to assign identifiers to clients
+	// connecting through the built in endpoint
+	IDGenerator func() (string, error)
+)
+
+// ShortID is the default id generator
+func ShortID() (string, error) {
+	return shortid.Generate()
+}
+
+// ServeHTTP upgrades the request to a websocket connection
+// and registers it as a new client on the server. When the server
+// has an authenticator the client has to give a token, either in
+// the handshake or as an auth event in its first message
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ident *Identity
+
+	id, err := s.GenerateID()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		http.Error(w, "unable to assign client id", http.StatusInternalServerError)
+		return
+	}
+
+	token := handshakeToken(r)
+
+	if s.Auth != nil && token != "" {
+		if ident, err = s.Auth.Authenticate(token); err != nil {
+			s.GM.Log.Warningf("Rejected connection from %s: %s", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Offer the codecs as subprotocols, the upgrader picks
+	// the first one the client also asked for
+	up := s.Upgrader
+	up.Subprotocols = s.subprotocols()
+	compression := s.GM.Settings.Compression
+	up.EnableCompression = up.EnableCompression || compression.Enabled
+
+	if up.CheckOrigin == nil {
+		up.CheckOrigin = s.checkOrigin
+	}
+
+	// The upgrader writes its own response on failure
+	ws, err := up.Upgrade(w, r, nil)
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		return
+	}
+
+	codec := s.codec(ws.Subprotocol())
+
+	if compression.Enabled {
+		if err := ws.SetCompressionLevel(compression.Level); err != nil {
+			s.GM.Log.Error(err)
+		}
+	}
+
+	if s.Auth != nil && ident == nil {
+		if ident, err = s.authenticateMessage(ws, codec); err != nil {
+			s.GM.Log.Warningf("Rejected connection from %s: %s", r.RemoteAddr, err)
+			refuse(ws, websocket.ClosePolicyViolation, ErrUnauthorized.Error())
+			return
+		}
+	}
+
+	conn := &WebsocketConnection{Conn: ws, Codec: codec, Compression: compression}
+	client := s.newClient(conn, id)
+	client.identify(ident)
+	client.Metadata.request("websocket", r)
+	client.Metadata.Set(ProtocolMeta, codec.Name())
+	client.resumeWith = r.URL.Query().Get("resume")
+
+	select {
+	case s.Register <- client:
+	case <-s.Shutdown:
+		refuse(ws, websocket.CloseGoingAway, "")
+	}
+}
+
+// Closes a websocket that never became a client
+func refuse(ws *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	ws.Close()
+}
+
+// ListenAndServe serves the websocket endpoint using the
+// address and path from the standard config, this should
+// be called once the game is running. The tcp and udp
+// listeners are also started when they have an address, http and
+// tcp are served over tls when a certificate is configured. After
+// Shutdown this returns http.ErrServerClosed
+func (GM *GameManager) ListenAndServe() error {
+	config, err := GM.TLSConfig()
+
+	if err != nil {
+		return err
+	}
+
+	if GM.Settings.TCP.Address != "" {
+		go func() {
+			if err := GM.ListenTCP(); err != nil {
+				GM.Log.Error(err)
+			}
+		}()
+	}
+
+	if GM.Settings.UDP.Address != "" {
+		go func() {
+			if err := GM.ListenUDP(); err != nil {
+				GM.Log.Error(err)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(GM.Settings.Server.Path, GM.Server)
+
+	if GM.Settings.SSE.Path != "" {
+		mux.Handle(GM.Settings.SSE.Path, NewSSEHandler(GM.Server))
+	}
+
+	srv := &http.Server{Addr: GM.Settings.Server.Address, Handler: mux, TLSConfig: config}
+
+	if !GM.listen(func() { GM.HTTP = srv }) {
+		return http.ErrServerClosed
+	}
+
+	GM.Log.Infof("Listening for connections on %s%s", GM.Settings.Server.Address, GM.Settings.Server.Path)
+
+	if config != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
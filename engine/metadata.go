@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RemoteAddrMeta is the address the client connected from
+	RemoteAddrMeta = "remoteAddr"
+
+	// TransportMeta is how the client connected, websocket, sse or tcp
+	TransportMeta = "transport"
+
+	// ProtocolMeta is the name of the codec the client is using
+	ProtocolMeta = "protocol"
+
+	// UserAgentMeta is the user agent given by http clients
+	UserAgentMeta = "userAgent"
+
+	// LocaleMeta is the preferred language given by http clients
+	LocaleMeta = "locale"
+
+	// ConnectedAtMeta is when the client was given its slot
+	ConnectedAtMeta = "connectedAt"
+)
+
+type (
+	// Metadata holds arbitrary values about a client, it is safe
+	// to use from multiple goroutines. Only the exposed keys are
+	// included when the client is serialized
+	Metadata struct {
+		mu     sync.RWMutex
+		values map[string]interface{}
+		expose []string
+	}
+)
+
+// NewMetadata creates an empty store, the given keys are the
+// ones included when it is serialized
+func NewMetadata(expose ...string) *Metadata {
+	return &Metadata{values: make(map[string]interface{}), expose: expose}
+}
+
+// Get returns the value stored under the key
+func (m *Metadata) Get(k string) (interface{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.values[k]
+	return v, ok
+}
+
+// String returns the value under the key if it is a string
+func (m *Metadata) String(k string) string {
+	v, _ := m.Get(k)
+	s, _ := v.(string)
+	return s
+}
+
+// Set stores the value under the key
+func (m *Metadata) Set(k string, v interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[k] = v
+}
+
+// Delete removes the key
+func (m *Metadata) Delete(k string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, k)
+}
+
+// All returns a copy of every value
+func (m *Metadata) All() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := make(map[string]interface{}, len(m.values))
+
+	for k, v := range m.values {
+		all[k] = v
+	}
+
+	return all
+}
+
+// MarshalJSON only includes the exposed keys
+func (m *Metadata) MarshalJSON() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exposed := make(map[string]interface{}, len(m.expose))
+
+	for _, k := range m.expose {
+		if v, ok := m.values[k]; ok {
+			exposed[k] = v
+		}
+	}
+
+	return json.Marshal(exposed)
+}
+
+// UnmarshalJSON reads the exposed values back, which lets clients
+// decode the client sent in the connected event
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	values := make(map[string]interface{})
+
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values = values
+
+	for k := range values {
+		m.expose = append(m.expose, k)
+	}
+
+	return nil
+}
+
+// Copies the values from another store, used when a client resumes
+// on a new connection with new transport details
+func (m *Metadata) merge(o *Metadata) {
+	for k, v := range o.All() {
+		m.Set(k, v)
+	}
+}
+
+// Records the details of a client that connected over http
+func (m *Metadata) request(transport string, r *http.Request) {
+	m.Set(TransportMeta, transport)
+	m.Set(RemoteAddrMeta, r.RemoteAddr)
+
+	if ua := r.UserAgent(); ua != "" {
+		m.Set(UserAgentMeta, ua)
+	}
+
+	// Only the most preferred language is kept
+	if lang := r.Header.Get("Accept-Language"); lang != "" {
+		lang = strings.TrimSpace(strings.SplitN(strings.SplitN(lang, ",", 2)[0], ";", 2)[0])
+		m.Set(LocaleMeta, lang)
+	}
+}
+
+// Marks when the client was given its slot
+func (m *Metadata) connected() {
+	m.Set(ConnectedAtMeta, time.Now())
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"sync"
+)
+
+type (
+	// Dispatch fires an event, at the end of the chain the event's
+	// definition is looked up and its checks are run
+	Dispatch func(e Event)
+
+	// Middleware wraps dispatch, it can change the event before calling
+	// next or stop the event by not calling next at all. Changes are
+	// made before the event is looked up and validated
+	Middleware func(next Dispatch) Dispatch
+
+	// ChannelDispatch delivers an event that has passed its
+	// definition's checks to a channel
+	ChannelDispatch func(e Event, d EventDefinition)
+
+	// ChannelMiddleware wraps the delivery to a channel
+	ChannelMiddleware func(next ChannelDispatch) ChannelDispatch
+
+	// The middleware registered on the game manager, the slices are
+	// replaced rather than appended to so a chain can be read
+	// without holding the lock
+	middlewares struct {
+		mu       sync.RWMutex
+		global   []Middleware
+		channels map[string][]ChannelMiddleware
+	}
+)
+
+// Use adds middleware around the dispatch of every event, including
+// the lookup of its definition and validation. The first middleware
+// added is the outermost
+func (GM *GameManager) Use(mw ...Middleware) {
+	m := GM.middleware
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.global = append(append([]Middleware(nil), m.global...), mw...)
+}
+
+// UseChannel adds middleware around the delivery of events to the
+// named channel, it runs after the global middleware
+func (GM *GameManager) UseChannel(n string, mw ...ChannelMiddleware) {
+	m := GM.middleware
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.channels == nil {
+		m.channels = make(map[string][]ChannelMiddleware)
+	}
+
+	m.channels[n] = append(append([]ChannelMiddleware(nil), m.channels[n]...), mw...)
+}
+
+// Wraps the dispatch in the global middleware
+func (GM *GameManager) chain(d Dispatch) Dispatch {
+	m := GM.middleware
+	m.mu.RLock()
+	mw := m.global
+	m.mu.RUnlock()
+
+	// Wraps from the inside out so the first middleware runs first
+	for i := len(mw) - 1; i >= 0; i-- {
+		d = mw[i](d)
+	}
+
+	return d
+}
+
+// Wraps the delivery to a channel in its middleware
+func (GM *GameManager) chainChannel(n string, d ChannelDispatch) ChannelDispatch {
+	m := GM.middleware
+	m.mu.RLock()
+	mw := m.channels[n]
+	m.mu.RUnlock()
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		d = mw[i](d)
+	}
+
+	return d
+}
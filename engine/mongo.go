@@ -56,6 +56,13 @@ func (m *Mongo) Connect() {
 	m.GM.Log.Info("Connected to mongo server...")
 }
 
+// Close closes the session if one has been created
+func (m *Mongo) Close() {
+	if m.Session != nil {
+		m.Session.Close()
+	}
+}
+
 // Save saves an entity and streams it back out
 func (m *Mongo) Save(c string, i interface{}) {
 	var bs bson.ObjectId
@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrMsgPackFormat is returned when decoding an unsupported format
+	ErrMsgPackFormat = errors.New("unsupported msgpack format")
+)
+
+// Writes a json style value as msgpack, this handles the types
+// produced by decoding json with UseNumber
+func encodeMsgPack(w *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		w.WriteByte(0xc0)
+
+	case bool:
+		if t {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			encodeMsgPackInt(w, i)
+			return nil
+		}
+
+		f, err := t.Float64()
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteByte(0xcb)
+		binary.Write(w, binary.BigEndian, math.Float64bits(f))
+
+	case float64:
+		w.WriteByte(0xcb)
+		binary.Write(w, binary.BigEndian, math.Float64bits(t))
+
+	case string:
+		encodeMsgPackLength(w, len(t), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		w.WriteString(t)
+
+	case []interface{}:
+		encodeMsgPackLength(w, len(t), 0x90, 15, 0, 0xdc, 0xdd)
+
+		for _, i := range t {
+			if err := encodeMsgPack(w, i); err != nil {
+				return err
+			}
+		}
+
+	case map[string]interface{}:
+		encodeMsgPackLength(w, len(t), 0x80, 15, 0, 0xde, 0xdf)
+
+		// Sorted keys keep the encoding stable
+		keys := make([]string, 0, len(t))
+
+		for k := range t {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			encodeMsgPack(w, k)
+
+			if err := encodeMsgPack(w, t[k]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("msgpack: can not encode %T", v)
+	}
+
+	return nil
+}
+
+// Writes an integer in the smallest format that fits
+func encodeMsgPackInt(w *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		w.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		w.WriteByte(0xd0)
+		w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		w.WriteByte(0xd1)
+		binary.Write(w, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		w.WriteByte(0xd2)
+		binary.Write(w, binary.BigEndian, int32(i))
+	default:
+		w.WriteByte(0xd3)
+		binary.Write(w, binary.BigEndian, i)
+	}
+}
+
+// Writes the header for a string, array or map. The fixed format is
+// used up to max, formats with a zero marker are skipped
+func encodeMsgPackLength(w *bytes.Buffer, n int, fixed byte, max int, b8 byte, b16 byte, b32 byte) {
+	switch {
+	case n <= max:
+		w.WriteByte(fixed | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(b8)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(b16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(b32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
+
+// Reads a single msgpack value into its json style equivalent
+func decodeMsgPack(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return decodeMsgPackString(r, int(b&0x1f))
+	case b&0xf0 == 0x90:
+		return decodeMsgPackArray(r, int(b&0x0f))
+	case b&0xf0 == 0x80:
+		return decodeMsgPackMap(r, int(b&0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		n, err := readMsgPackUint(r, 1)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackString)
+	case 0xc5, 0xda:
+		n, err := readMsgPackUint(r, 2)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackString)
+	case 0xc6, 0xdb:
+		n, err := readMsgPackUint(r, 4)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackString)
+	case 0xca:
+		n, err := readMsgPackUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := readMsgPackUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgPackUint(r, 1<<(b-0xcc))
+
+		// Anything over int64 is kept as a float rather than wrapping
+		if n > math.MaxInt64 {
+			return float64(n), err
+		}
+
+		return int64(n), err
+	case 0xd0:
+		n, err := readMsgPackUint(r, 1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := readMsgPackUint(r, 2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := readMsgPackUint(r, 4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := readMsgPackUint(r, 8)
+		return int64(n), err
+	case 0xdc:
+		n, err := readMsgPackUint(r, 2)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackArray)
+	case 0xdd:
+		n, err := readMsgPackUint(r, 4)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackArray)
+	case 0xde:
+		n, err := readMsgPackUint(r, 2)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackMap)
+	case 0xdf:
+		n, err := readMsgPackUint(r, 4)
+		return decodeMsgPackLength(r, n, err, decodeMsgPackMap)
+	}
+
+	return nil, ErrMsgPackFormat
+}
+
+// Reads a big endian unsigned integer of the given size
+func readMsgPackUint(r *bytes.Reader, size int) (uint64, error) {
+	buf := make([]byte, 8)
+
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// Decodes a value whose length has just been read
+func decodeMsgPackLength(r *bytes.Reader, n uint64, err error, fn func(*bytes.Reader, int) (interface{}, error)) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	// The length can't be more than what is left to read
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return fn(r, int(n))
+}
+
+func decodeMsgPackString(r *bytes.Reader, n int) (interface{}, error) {
+	buf := make([]byte, n)
+
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return string(buf), nil
+}
+
+func decodeMsgPackArray(r *bytes.Reader, n int) (interface{}, error) {
+	out := make([]interface{}, 0, n)
+
+	for i := 0; i < n; i++ {
+		v, err := decodeMsgPack(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+func decodeMsgPackMap(r *bytes.Reader, n int) (interface{}, error) {
+	out := make(map[string]interface{}, n)
+
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgPack(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := decodeMsgPack(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		out[fmt.Sprint(k)] = v
+	}
+
+	return out, nil
+}
@@ -1,8 +1,12 @@
 package engine
 
 import (
+	"context"
 	"errors"
+	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"gopkg.in/mgo.v2/bson"
@@ -14,6 +18,27 @@ const (
 
 	// DisconnectedEvent constant value for the disconnected event
 	DisconnectedEvent = "disconnected"
+
+	// ResumedEvent constant value for the resumed event
+	ResumedEvent = "resumed"
+
+	// BlockPolicy waits for room in a full client queue
+	BlockPolicy = "block"
+
+	// DropOldestPolicy discards the oldest queued event to make room
+	DropOldestPolicy = "drop-oldest"
+
+	// DropNewestPolicy discards the event being queued
+	DropNewestPolicy = "drop-newest"
+
+	// DisconnectPolicy disconnects clients that can't keep up
+	DisconnectPolicy = "disconnect"
+)
+
+var (
+	// ErrServerClosed is returned when a client connects after
+	// the server has started shutting down
+	ErrServerClosed = errors.New("server is shutting down")
 )
 
 type (
@@ -21,11 +46,43 @@ type (
 	// Client represents a connected client/user
 	Client struct {
 		ID          string              `json:"id"`
+		UserID      string              `json:"userId"`
 		MId         bson.ObjectId       `bson:"_id" json:"-"`
 		Conn        ConnectionInterface `json:"-"`
 		Send        chan Event          `json:"-"`
 		Traits      *sync.Map           `json:"-"`
 		Subscribers *sync.Map           `json:"-"`
+		ResumeToken string              `json:"resumeToken,omitempty"`
+		UDPToken    string              `json:"udpToken,omitempty"`
+		Claims      Claims              `json:"-"`
+		// Metadata is kept for the lifetime of the client, only
+		// the keys exposed in the settings are serialized
+		Metadata *Metadata `json:"metadata"`
+
+		// Rate limit buckets keyed by event name
+		limits sync.Map
+
+		// mu guards the connection state, quitMu only guards
+		// closing quit since pushes can block holding mu
+		mu        sync.RWMutex
+		quitMu    sync.Mutex
+		closed    bool
+		suspended bool
+		gone      bool
+		queued    bool
+		epoch     int
+		policy    string
+		server    *Server
+		udp       *udpBinding
+		quit      chan struct{}
+		done      chan struct{}
+
+		// Events held while the client is suspended
+		missedMu sync.Mutex
+		missed   []Event
+
+		// The token the client asked to resume with
+		resumeWith string
 	}
 
 	// ConnectionInterface defines what we expect from a connection
@@ -43,13 +100,56 @@ type (
 		Register   chan *Client
 		Unregister chan *Client
 		Shutdown   chan bool
+		Sessions   *sync.Map
+		Auth       Authenticator
+		Codecs     []Codec
+		Upgrader   websocket.Upgrader
+		GenerateID IDGenerator
+
+		mu       sync.Mutex
+		detached chan detachment
+		expired  chan expiry
+
+		// The login queue and the number of clients holding a
+		// slot, both are guarded by mu
+		waiting    []*Client
+		admitted   int
+		announcing bool
+
+		// The live sessions of each user, guarded by mu
+		users map[string][]*Client
+
+		// udp is set once the server is reading udp packets
+		udp        net.PacketConn
+		udpTokens  sync.Map
+		udpClients sync.Map
+	}
+
+	// Tracks when a connection last received an event
+	activity struct {
+		lastRead int64
+	}
+
+	// Reports that a connection has gone, the connection is kept
+	// so a late report can't end a session that has since resumed
+	detachment struct {
+		client *Client
+		conn   ConnectionInterface
 	}
 
 	// WebsocketConnection is the default connection used
 	// provides a websocket reader and writer for the client to
 	// connect to
 	WebsocketConnection struct {
+		// activity is accessed atomically so is kept first
+		// for alignment
+		activity
 		Conn *websocket.Conn
+		// Codec defaults to json when not set
+		Codec Codec
+		// Compression decides which frames are compressed, when
+		// the client negotiated permessage-deflate
+		Compression CompressionSettings
 	}
 )
 
@@ -61,11 +161,23 @@ func NewServer(GM *GameManager) *Server {
 		Channels:   new(sync.Map),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
+		Shutdown:   make(chan bool),
+		Sessions:   new(sync.Map),
+		Codecs:     DefaultCodecs(),
+		GenerateID: ShortID,
+		detached:   make(chan detachment),
+		expired:    make(chan expiry),
+		users:      make(map[string][]*Client),
 	}
 
 	// Register events
 	GM.Event(EventDefinition{Name: ConnectedEvent, Channels: []string{InternalChan, DirectChan}})
 	GM.Event(EventDefinition{Name: DisconnectedEvent, Channels: []string{InternalChan}})
+	GM.Event(EventDefinition{Name: ResumedEvent, Channels: []string{InternalChan, DirectChan}})
+	GM.Event(EventDefinition{Name: ErrorEvent, Channels: []string{DirectChan}})
+	GM.Event(EventDefinition{Name: RateLimitedEvent, Channels: []string{InternalChan}})
+	GM.Event(EventDefinition{Name: ReplyEvent, Channels: []string{DirectChan}})
+	GM.Event(EventDefinition{Name: QueuePositionEvent, Channels: []string{DirectChan}})
 
 	// Add the default channels
 	serv.NewChannels(map[string]ChannelInterface{
@@ -78,30 +190,227 @@ func NewServer(GM *GameManager) *Server {
 }
 
 // NewClient creates a new client from the given details
+// using the default queue settings
 func NewClient(c ConnectionInterface, id string) *Client {
+	return NewQueuedClient(c, id, DefaultSettings().Queue)
+}
+
+// NewQueuedClient creates a new client whose outbound queue
+// uses the given settings
+func NewQueuedClient(c ConnectionInterface, id string, q QueueSettings) *Client {
 	return &Client{
 		ID:          id,
 		Conn:        c,
-		Send:        make(chan Event),
+		Send:        make(chan Event, q.Size),
 		Traits:      new(sync.Map),
 		Subscribers: new(sync.Map),
+		Metadata:    NewMetadata(),
+		policy:      q.Policy,
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
 	}
 }
 
-// RegisterHandler registers a handler for an Instanced component
-// all handlers for instances will bind to the `direct` channel
-// this means they will be presented with all personalised events
-func (c *Client) RegisterHandler(n string, h EventHandler) {
-	var handlers []EventHandler
+// Push queues an event to be written to the client, false is returned
+// when the event could not be queued, either because the client has been
+// closed or because the queue was full and the policy discarded it
+func (c *Client) Push(e Event) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Direct events are kept for suspended clients to replay,
+	// unreliable ones will be stale by the time it resumes
+	if c.suspended {
+		if !e.Broadcast && !e.unreliable {
+			c.hold(e)
+		}
+
+		return false
+	}
+
+	if c.closed {
+		return false
+	}
+
+	// Unreliable events skip the queue when the client has bound udp
+	if e.unreliable && c.udp != nil {
+		if err := c.udp.send(e, c.server.codec(c.server.GM.Settings.UDP.Codec)); err != nil {
+			c.server.GM.Log.Error(err)
+			return false
+		}
+
+		return true
+	}
+
+	switch c.policy {
+	case DropNewestPolicy:
+		select {
+		case c.Send <- e:
+			return true
+		default:
+			return false
+		}
+
+	case DropOldestPolicy:
+		for {
+			select {
+			case c.Send <- e:
+				return true
+			default:
+			}
+
+			// The writer may empty the queue in the meantime
+			select {
+			case <-c.Send:
+			default:
+			}
+		}
+
+	case DisconnectPolicy:
+		select {
+		case c.Send <- e:
+			return true
+		default:
+		}
+
+		// Disconnecting closes the client, which needs the lock
+		// we are holding, so this has to happen separately
+		if c.server != nil {
+			c.server.GM.Log.Warningf("Client %s is not keeping up, disconnecting", c.ID)
+			go c.server.detach(c, c.Conn)
+		}
+
+		return false
+	}
+
+	select {
+	case c.Send <- e:
+		return true
+	case <-c.quit:
+		return false
+	}
+}
+
+// Queues the event only if there is room for it right away, used for
+// updates that are fine to lose whatever the queue policy
+func (c *Client) offer(e Event) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.suspended || c.closed {
+		return false
+	}
+
+	select {
+	case c.Send <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// Depth returns the number of events waiting to be written
+func (c *Client) Depth() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.Send)
+}
+
+// Close closes the send channel so the writer can drain what is
+// left, this returns false if the client was already closed
+func (c *Client) Close() bool {
+	c.release()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closeSend()
+}
+
+// Done is closed once the clients writer has finished
+func (c *Client) Done() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.done
+}
+
+// Suspended checks whether the client is waiting to resume
+func (c *Client) Suspended() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.suspended
+}
+
+// Releases anything blocked on a push, this has to happen
+// before the lock can be taken
+func (c *Client) release() {
+	c.quitMu.Lock()
+	defer c.quitMu.Unlock()
+
+	select {
+	case <-c.quit:
+	default:
+		close(c.quit)
+	}
+}
+
+// Closes the send channel, the lock must be held
+func (c *Client) closeSend() bool {
+	if c.closed {
+		return false
+	}
+
+	c.closed = true
+	close(c.Send)
 
-	reg, ok := c.Subscribers.Load(n)
+	return true
+}
+
+// Marks the client as gone for good, returns false
+// if it had already left
+func (c *Client) leave() bool {
+	c.release()
 
-	if ok {
-		handlers = reg.([]EventHandler)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gone {
+		return false
 	}
 
-	handlers = append(handlers, h)
-	c.Subscribers.Store(n, handlers)
+	c.gone = true
+	c.suspended = false
+	c.closeSend()
+
+	c.missedMu.Lock()
+	c.missed = nil
+	c.missedMu.Unlock()
+
+	return true
+}
+
+// The connection the client is currently using
+func (c *Client) connection() ConnectionInterface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Conn
+}
+
+// RegisterHandler registers a handler for an Instanced component
+// all handlers for instances will bind to the `direct` channel
+// this means they will be presented with all personalised events
+func (c *Client) RegisterHandler(n string, h EventHandler) *Subscription {
+	return c.RegisterPriorityHandler(n, DefaultPriority, h)
+}
+
+// RegisterPriorityHandler registers a handler for an Instanced component
+// with a priority, see GameManager.RegisterPriorityHandler
+func (c *Client) RegisterPriorityHandler(n string, p int, h EventHandler) *Subscription {
+	return subscribe(c.Subscribers, n, p, h)
 }
 
 // RemoveTrait removes the trait for the clients list of traits
@@ -119,6 +428,11 @@ func (c *Client) RemoveTrait(n string) {
 
 	trait.Destroy()
 	c.Traits.Delete(n)
+
+	// Handlers the trait registered would otherwise keep firing
+	if r, ok := trait.(handlerReleaser); ok {
+		r.releaseHandlers()
+	}
 }
 
 // BindTrait adds a new instance to the client
@@ -146,7 +460,7 @@ func (s *Server) Forward(n string, e Event, d EventDefinition) {
 	}
 
 	// if so, forward the event
-	ch.Send(e, d)
+	s.send(n, ch, e, d)
 }
 
 // SendToChannels uses the channels on an event definition to send
@@ -160,10 +474,23 @@ func (s *Server) SendToChannels(e Event, d EventDefinition) {
 			continue
 		}
 
-		ch.Send(e, d)
+		s.send(v, ch, e, d)
 	}
 }
 
+// Sends the event to a single channel through its middleware, a
+// panic in one channel shouldn't stop the others receiving the event
+func (s *Server) send(n string, ch ChannelInterface, e Event, d EventDefinition) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.GM.Log.Error(r)
+			s.GM.Reject(e, HandlerPanicError, "the server failed to handle the event")
+		}
+	}()
+
+	s.GM.chainChannel(n, ch.Send)(e, d)
+}
+
 // NewChannels creates and adds channels to the store
 func (s *Server) NewChannels(c map[string]ChannelInterface) {
 	for k, v := range c {
@@ -196,6 +523,14 @@ func (s *Server) FindChannel(n string) (ChannelInterface, error) {
 	return ch.(ChannelInterface), nil
 }
 
+// Creates a client for a connection using the queue settings
+func (s *Server) newClient(c ConnectionInterface, id string) *Client {
+	client := NewQueuedClient(c, id, s.GM.Settings.Queue)
+	client.Metadata = NewMetadata(s.GM.Settings.Metadata.Expose...)
+
+	return client
+}
+
 // Find attempts to get a client by its identifier
 func (s *Server) Find(id string) (*Client, error) {
 	cl, ok := s.Clients.Load(id)
@@ -209,29 +544,182 @@ func (s *Server) Find(id string) (*Client, error) {
 
 // Connect adds a new client to the server
 func (s *Server) Connect(client *Client) {
-	s.Clients.Store(client.ID, client)
+	s.mu.Lock()
+
+	if s.Closed() {
+		s.mu.Unlock()
+
+		// Closing before the writer starts means it will only
+		// send the close frame
+		client.Close()
+		go s.write(client, client.done)
+		return
+	}
+
+	if client.resumeWith != "" {
+		// Authenticated sessions can only be resumed by the same identity
+		if sess, ok := s.Sessions.Load(client.resumeWith); ok && (s.Auth == nil || sess.(*Client).UserID == client.UserID) {
+			// The token may have been seen in a url, so each
+			// one can only be used once
+			s.Sessions.Delete(client.resumeWith)
+			s.mu.Unlock()
+			s.resume(sess.(*Client), client)
+			return
+		}
+
+		s.GM.Log.Warningf("Unknown resume token, %s is starting a new session", client.ID)
+	}
+
+	// Ids are the key of the client on the server so can't be shared
+	if s.registered(client.ID) {
+		s.mu.Unlock()
+
+		s.GM.Log.Warningf("Client id %s is already in use, turning the connection away", client.ID)
+
+		// Leaving means a later disconnect can't touch the slot
+		// or sessions of the client that holds the id
+		client.leave()
+		go s.write(client, client.done)
+		return
+	}
+
+	client.server = s
+	kick, ok := s.duplicates(client)
+
+	if !ok {
+		s.mu.Unlock()
+
+		s.GM.Log.Warningf("User %s is already connected, turning away client %s", client.UserID, client.ID)
+
+		client.Push(duplicateSession(client))
+		client.Close()
+		go s.write(client, client.done)
+		return
+	}
+
+	// Clients over the cap wait in the login queue, their
+	// connection is kept open so they can be told their position.
+	// The slots of older sessions being ended are handed over
+	if !s.admits(client, len(kick)) {
+		pos := s.enqueue(client)
+		s.mu.Unlock()
+		s.kick(kick)
+
+		s.GM.Log.Infof("Server is full, client %s is waiting in the login queue", client.ID)
+
+		go client.Conn.Reader(client, s)
+		go s.write(client, client.done)
+
+		client.Push(pos)
+		return
+	}
+
+	s.admit(client)
+	s.mu.Unlock()
+	s.kick(kick)
 
 	s.GM.Log.Infof("Connecting new client %s", client.ID)
 
 	go client.Conn.Reader(client, s)
-	go client.Conn.Writer(client, s)
+	go s.write(client, client.done)
 
 	s.GM.FireEvent(NewDirectEvent(ConnectedEvent, client, client.ID))
 }
 
-// Disconnect removes a client from the server
+// Checks whether a client with the id is connected or waiting for
+// a slot, called under the lock
+func (s *Server) registered(id string) bool {
+	if _, ok := s.Clients.Load(id); ok {
+		return true
+	}
+
+	for _, c := range s.waiting {
+		if c.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Runs the clients writer, marking the client as done once
+// everything has been written
+func (s *Server) write(client *Client, done chan struct{}) {
+	defer close(done)
+	client.Conn.Writer(client, s)
+}
+
+// Disconnect removes a client from the server, clients that can
+// resume are suspended instead until their grace period is over
 func (s *Server) Disconnect(client *Client) {
-	s.Clients.Delete(client.ID)
-	close(client.Send)
+	if s.resumable(client) {
+		s.suspend(client)
+		return
+	}
+
+	s.leave(client)
+}
+
+// Removes the client and lets components know it has gone
+func (s *Server) leave(client *Client) {
+	// Clients still in the login queue never connected
+	if s.dequeue(client) {
+		client.leave()
+		return
+	}
+
+	if !s.remove(client) {
+		return
+	}
 
 	s.GM.FireEvent(NewDirectEvent(DisconnectedEvent, client, client.ID))
 }
 
+// Removes the client from the server and closes it, freeing its
+// slot for the login queue. Returns false if the client was
+// already disconnected
+func (s *Server) remove(client *Client) bool {
+	if !client.leave() {
+		return false
+	}
+
+	// Only the entry for this client is removed, the id may
+	// have been turned away while it was in use
+	s.mu.Lock()
+	if c, ok := s.Clients.Load(client.ID); ok && c.(*Client) == client {
+		s.Clients.Delete(client.ID)
+	}
+
+	// Resuming replaces the token under the lock
+	if client.ResumeToken != "" {
+		s.Sessions.Delete(client.ResumeToken)
+	}
+	s.mu.Unlock()
+
+	s.removeUDP(client)
+	s.removeSession(client)
+	s.release(client)
+
+	return true
+}
+
+// Queues a client to be disconnected by the server loop because the
+// given connection has gone, unless the server is already shutting down
+func (s *Server) detach(client *Client, conn ConnectionInterface) {
+	select {
+	case s.detached <- detachment{client: client, conn: conn}:
+	case <-s.Shutdown:
+	}
+}
+
 // Broadcast sends a message to all connected clients
 func (s *Server) Broadcast(e Event) {
+	e.Broadcast = true
+	e = e.shared()
+
 	s.Clients.Range(func(k, v interface{}) bool {
 		client := v.(*Client)
-		client.Send <- e
+		client.Push(e)
 		return true
 	})
 }
@@ -239,62 +727,276 @@ func (s *Server) Broadcast(e Event) {
 // Reader reads messages from the client and processess
 // them as events
 func (ws *WebsocketConnection) Reader(c *Client, s *Server) {
-readloop:
-	for {
-		var e Event
+	hb := s.GM.Settings.Heartbeat
 
-		if err := ws.Conn.ReadJSON(&e); err != nil {
+	// Any pong or message from the client extends the deadline
+	ws.Conn.SetReadDeadline(deadline(hb.PongWait))
+	ws.Conn.SetPongHandler(func(string) error {
+		return ws.Conn.SetReadDeadline(deadline(hb.PongWait))
+	})
+	ws.touch()
 
-			s.GM.Log.Error(err)
+	for {
+		_, msg, err := ws.Conn.ReadMessage()
 
-			// Catch for client disconnects
-			if _, k := err.(*websocket.CloseError); k {
-				s.Unregister <- c
-				break readloop
+		// Any read error means the connection is no longer usable,
+		// this includes missing the read deadline
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.GM.Log.Error(err)
 			}
+
+			s.detach(c, ws)
+			return
 		}
 
-		// Set the info we already know about the event
-		e.ClientID = c.ID
-		// We also know this was of the inbound origin
-		e.Origin = ClientOrigin
+		ws.Conn.SetReadDeadline(deadline(hb.PongWait))
+		ws.touch()
+
+		var e Event
+
+		if err := ws.codec().Unmarshal(msg, &e); err != nil {
+			s.GM.Log.Error(err)
+			continue
+		}
 
-		// Finally fire the event
-		s.GM.FireEvent(e)
+		s.Receive(c, e)
 	}
 }
 
-// Writer writes messages to the given client
+// Writer writes messages to the given client, it also pings
+// the client and closes the connection once it has been idle
+// for too long
 func (ws *WebsocketConnection) Writer(c *Client, s *Server) {
-writerloop:
+	hb := s.GM.Settings.Heartbeat
+	defer ws.Conn.Close()
+
+	var ping <-chan time.Time
+
+	if hb.PingInterval > 0 {
+		ticker := time.NewTicker(hb.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	// Once a write fails the connection is closed, which stops the
+	// reader, we keep draining the queue until the client is removed
+	broken := false
+
 	for {
 		select {
 		case event, ok := <-c.Send:
-
 			if !ok {
-				// At this point, we would assume a disconnection
-				break writerloop
+				// The send channel has been drained, let the client know why
+				ws.close(s, hb)
+				return
 			}
 
-			if err := ws.Conn.WriteJSON(event); err != nil {
+			events, open := c.gather(event, s.GM.Settings.Batch)
+
+			if !broken {
+				if err := ws.flush(events, s.GM.Settings.Batch, hb); err != nil {
+					s.GM.Log.Error(err)
+					s.GM.Log.Errorf("Unable to process events: %+v", events)
+					ws.Conn.Close()
+					broken = true
+				}
+			}
+
+			if !open {
+				ws.close(s, hb)
+				return
+			}
+
+		case <-ping:
+			if broken {
+				continue
+			}
+
+			if hb.MaxIdle > 0 && ws.idle() > hb.MaxIdle {
+				s.GM.Log.Infof("Client %s has been idle for too long", c.ID)
+				ws.Conn.Close()
+				broken = true
+				continue
+			}
+
+			if err := ws.Conn.WriteControl(websocket.PingMessage, nil, deadline(hb.WriteTimeout)); err != nil {
 				s.GM.Log.Error(err)
-				s.GM.Log.Errorf("Unable to process event: %+v", event)
+				ws.Conn.Close()
+				broken = true
 			}
 		}
 	}
 }
 
+// Encodes and writes a single event
+func (ws *WebsocketConnection) write(e Event, hb HeartbeatSettings) error {
+	codec := ws.codec()
+	data, err := e.encode(codec)
+
+	if err != nil {
+		return err
+	}
+
+	return ws.send(codec, data, hb)
+}
+
+// Writes the gathered events, as one array frame when batching
+func (ws *WebsocketConnection) flush(events []Event, b BatchSettings, hb HeartbeatSettings) error {
+	if b.Size <= 1 {
+		for _, e := range events {
+			if err := ws.write(e, hb); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	codec := ws.codec()
+	data, err := encodeBatch(events, codec)
+
+	if err != nil {
+		return err
+	}
+
+	return ws.send(codec, data, hb)
+}
+
+// Writes a single frame, only compressing it when it is large enough
+func (ws *WebsocketConnection) send(codec Codec, data []byte, hb HeartbeatSettings) error {
+	if ws.Compression.Enabled {
+		ws.Conn.EnableWriteCompression(len(data) >= ws.Compression.MinSize)
+	}
+
+	ws.Conn.SetWriteDeadline(deadline(hb.WriteTimeout))
+	return ws.Conn.WriteMessage(messageType(codec), data)
+}
+
+// The codec the connection uses
+func (ws *WebsocketConnection) codec() Codec {
+	if ws.Codec == nil {
+		return JSONCodec{}
+	}
+
+	return ws.Codec
+}
+
+// Sends the close frame, going away is used when the
+// server is shutting down
+func (ws *WebsocketConnection) close(s *Server, hb HeartbeatSettings) {
+	code := websocket.CloseNormalClosure
+
+	if s.Closed() {
+		code = websocket.CloseGoingAway
+	}
+
+	msg := websocket.FormatCloseMessage(code, "")
+	ws.Conn.WriteControl(websocket.CloseMessage, msg, deadline(hb.WriteTimeout))
+}
+
+// Records that the client has just sent something
+func (a *activity) touch() {
+	atomic.StoreInt64(&a.lastRead, time.Now().UnixNano())
+}
+
+// How long it has been since the client last sent something
+func (a *activity) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.lastRead)))
+}
+
+// Creates a deadline from now, zero durations mean no deadline
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(d)
+}
+
 // Listen starts the server loop
 func (s *Server) Listen() {
-servloop:
 	for {
 		select {
 		case r := <-s.Register:
 			s.Connect(r)
 		case u := <-s.Unregister:
 			s.Disconnect(u)
+		case d := <-s.detached:
+			// Ignore connections the client has since replaced
+			if d.client.connection() == d.conn {
+				s.Disconnect(d.client)
+			}
+		case x := <-s.expired:
+			if x.client.expired(x.epoch) {
+				s.leave(x.client)
+			}
 		case <-s.Shutdown:
-			break servloop
+			return
 		}
 	}
 }
+
+// Closed checks whether the server has started shutting down
+func (s *Server) Closed() bool {
+	select {
+	case <-s.Shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the server accepting clients, disconnects everyone
+// currently connected and closes the registered channels. Clients
+// are given until the context is done to drain their events
+func (s *Server) Close(ctx context.Context) error {
+	s.mu.Lock()
+
+	if s.Closed() {
+		s.mu.Unlock()
+		return nil
+	}
+
+	close(s.Shutdown)
+	s.mu.Unlock()
+
+	var clients []*Client
+
+	s.Clients.Range(func(k, v interface{}) bool {
+		clients = append(clients, v.(*Client))
+		return true
+	})
+
+	// Components are told about each disconnect before the
+	// channels they may rely on are closed
+	for _, client := range clients {
+		if s.remove(client) {
+			s.GM.fireSync(NewDirectEvent(DisconnectedEvent, client, client.ID))
+		}
+	}
+
+	var err error
+
+	// Clients in the login queue are simply closed
+	clients = append(clients, s.drainQueue()...)
+
+	for _, client := range clients {
+		select {
+		case <-client.Done():
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	s.Channels.Range(func(k, v interface{}) bool {
+		v.(ChannelInterface).Close()
+		return true
+	})
+
+	return err
+}
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitedEvent constant value for the internal event fired
+	// when a client goes over its rate limit
+	RateLimitedEvent = "ratelimit.exceeded"
+
+	// DropAction silently drops events over the limit
+	DropAction = "drop"
+
+	// WarnAction drops events over the limit and tells the client
+	WarnAction = "warn"
+
+	// DisconnectAction disconnects clients that go over the limit
+	DisconnectAction = "disconnect"
+
+	// The key used for the limit on all of a clients events
+	globalLimit = "*"
+)
+
+type (
+	// RateLimit is a token bucket limit, clients can fire Burst
+	// events at once, refilled at Rate events per second.
+	// A zero rate means no limit and a zero burst is taken as one
+	RateLimit struct {
+		Rate  float64 `yaml:"rate"`
+		Burst int     `yaml:"burst"`
+	}
+
+	// A token bucket for a single client, over is set once an
+	// event has been refused so the violation is only reported once
+	bucket struct {
+		mu     sync.Mutex
+		limit  RateLimit
+		tokens float64
+		last   time.Time
+		over   bool
+	}
+)
+
+// Creates a full bucket for the limit
+func newBucket(l RateLimit) *bucket {
+	return &bucket{limit: l, tokens: l.burst(), last: time.Now()}
+}
+
+// The most tokens a bucket can hold, a limit with only a rate
+// allows one event at a time
+func (l RateLimit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+
+	return float64(l.Burst)
+}
+
+// Adds the tokens earned since the last refill, called under the lock
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	b.last = now
+
+	if most := b.limit.burst(); b.tokens > most {
+		b.tokens = most
+	}
+}
+
+// Gets the clients bucket for the given key, creating it if needed.
+// Nil is returned when the limit has no rate
+func (c *Client) bucket(key string, l RateLimit) *bucket {
+	if l.Rate <= 0 {
+		return nil
+	}
+
+	b, ok := c.limits.Load(key)
+
+	if !ok {
+		b, _ = c.limits.LoadOrStore(key, newBucket(l))
+	}
+
+	return b.(*bucket)
+}
+
+// Takes a token from every bucket if they all have one, otherwise
+// nothing is taken. When refused the second value is true only for
+// the first refusal since tokens were last taken from those buckets
+func take(buckets []*bucket) (bool, bool) {
+	now := time.Now()
+	allowed := true
+
+	// Buckets are always given in the same order so this can't deadlock
+	for _, b := range buckets {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.refill(now)
+		allowed = allowed && b.tokens >= 1
+	}
+
+	if !allowed {
+		report := false
+
+		for _, b := range buckets {
+			if b.tokens < 1 {
+				report = report || !b.over
+				b.over = true
+			}
+		}
+
+		return false, report
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+		b.over = false
+	}
+
+	return true, false
+}
+
+// Receive handles an event read from a client, connections should
+// use this rather than firing events themselves so that the origin
+// and rate limits are applied
+func (s *Server) Receive(c *Client, e Event) {
+	// Set the info we already know about the event
+	e.ClientID = c.ID
+	e.UserID = c.UserID
+	// We also know this was of the inbound origin
+	e.Origin = ClientOrigin
+
+	// Nothing is handled until the client has a slot
+	if c.Queued() {
+		s.GM.Log.Warningf("Client %s sent %s while in the login queue", c.ID, e.Name)
+		return
+	}
+
+	if ok, report := s.withinLimits(c, e); !ok {
+		s.limited(c, e, report)
+		return
+	}
+
+	s.GM.FireEvent(e)
+}
+
+// Checks the event against the global and event limits, tokens are
+// only taken when both allow it. The second value says whether the
+// violation should be reported
+func (s *Server) withinLimits(c *Client, e Event) (bool, bool) {
+	var buckets []*bucket
+
+	if b := c.bucket(globalLimit, s.GM.Settings.RateLimit.RateLimit); b != nil {
+		buckets = append(buckets, b)
+	}
+
+	if def, ok := s.GM.Events.Load(e.Name); ok && def.(EventDefinition).RateLimit != nil {
+		if b := c.bucket(e.Name, *def.(EventDefinition).RateLimit); b != nil {
+			buckets = append(buckets, b)
+		}
+	}
+
+	return take(buckets)
+}
+
+// Carries out the configured action for a client over its limit,
+// the hook and warning only go out once each time it goes over so
+// a flooding client doesn't cost more than the events it sends
+func (s *Server) limited(c *Client, e Event, report bool) {
+	action := s.GM.Settings.RateLimit.Action
+
+	if report {
+		s.GM.Log.Warningf("Client %s is over its rate limit, %s dropped (%s)", c.ID, e.Name, action)
+		s.GM.FireEvent(NewDirectEvent(RateLimitedEvent, e, c.ID))
+	}
+
+	switch action {
+	case WarnAction:
+		if report {
+			s.GM.Reject(e, RateLimitedError, "too many events, slow down")
+		}
+	case DisconnectAction:
+		s.detach(c, c.connection())
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// ReplyEvent constant value for the event carrying a response
+	// back to the client that made a request
+	ReplyEvent = "reply"
+)
+
+var (
+	// ErrNoResponder is returned when requesting an event nothing responds to
+	ErrNoResponder = errors.New("no responder registered for event")
+
+	// ErrRequestTimeout is returned when a responder takes too long
+	ErrRequestTimeout = errors.New("request timed out")
+
+	// Returned to the requester when its responder panics
+	errResponderPanic = errors.New("the server failed to handle the event")
+)
+
+type (
+	// RequestHandler responds to an event, the result is sent back
+	// to whoever fired the event using the events correlation id
+	RequestHandler func(e Event) (interface{}, error)
+
+	// The outcome of a request
+	response struct {
+		data interface{}
+		err  error
+	}
+)
+
+// Respond registers the handler that answers requests for the event,
+// only one responder can be registered per event
+func (GM *GameManager) Respond(n string, h RequestHandler) {
+	GM.Responders.Store(n, h)
+}
+
+// Request fires an event and waits for its responder to answer, this
+// lets components ask each other questions over the internal channel
+func (GM *GameManager) Request(n string, d interface{}) (interface{}, error) {
+	if _, ok := GM.Responders.Load(n); !ok {
+		return nil, ErrNoResponder
+	}
+
+	e := NewEvent(n, d)
+	e.CorrelationID = e.ID
+
+	wait := make(chan response, 1)
+	GM.pending.Store(e.CorrelationID, wait)
+	defer GM.pending.Delete(e.CorrelationID)
+
+	GM.FireEvent(e)
+
+	select {
+	case r := <-wait:
+		return r.data, r.err
+	case <-time.After(GM.Settings.RPC.Timeout):
+		return nil, ErrRequestTimeout
+	}
+}
+
+// Runs the responder for a request, giving up once the timeout
+// has passed, and sends back whatever the outcome was
+func (GM *GameManager) respond(e Event, h RequestHandler) {
+	done := make(chan response, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				GM.Log.Error(r)
+				done <- response{err: errResponderPanic}
+			}
+		}()
+
+		data, err := h(e)
+		done <- response{data: data, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		GM.reply(e, r)
+	case <-time.After(GM.Settings.RPC.Timeout):
+		GM.Log.Warningf("Responder for %s timed out", e.Name)
+		GM.reply(e, response{err: ErrRequestTimeout})
+	}
+}
+
+// Sends the response to a client as a reply or error event, internal
+// requests are handed to whoever is waiting on them
+func (GM *GameManager) reply(e Event, r response) {
+	if e.Origin != ClientOrigin {
+		if wait, ok := GM.pending.Load(e.CorrelationID); ok {
+			wait.(chan response) <- r
+		}
+
+		return
+	}
+
+	switch r.err {
+	case nil:
+		ev := NewDirectEvent(ReplyEvent, r.data, e.ClientID)
+		ev.CorrelationID = e.CorrelationID
+		GM.FireEvent(ev)
+	case ErrRequestTimeout:
+		GM.Reject(e, TimeoutError, r.err.Error())
+	case errResponderPanic:
+		GM.Reject(e, HandlerPanicError, r.err.Error())
+	default:
+		GM.Reject(e, RequestFailedError, r.err.Error())
+	}
+}
@@ -0,0 +1,431 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// Schema is a compiled json schema, it supports the core draft-07
+	// keywords type, required, properties, additionalProperties, enum,
+	// items, pattern and the min and max keywords, as well as the
+	// true and false schemas
+	Schema struct {
+		// never is set for the false schema, which matches nothing
+		never      bool
+		types      []string
+		required   []string
+		properties map[string]*Schema
+		// additional is nil when any property is allowed
+		additional *Schema
+		enum       []interface{}
+		items      *Schema
+		tuple      []*Schema
+		pattern    *regexp.Regexp
+
+		minimum, maximum                   *float64
+		exclusiveMinimum, exclusiveMaximum *float64
+		minLength, maxLength               *int
+		minItems, maxItems                 *int
+	}
+
+	// The json form of a schema
+	rawSchema struct {
+		Type                 json.RawMessage            `json:"type"`
+		Required             []string                   `json:"required"`
+		Properties           map[string]json.RawMessage `json:"properties"`
+		AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+		Enum                 []interface{}              `json:"enum"`
+		Items                json.RawMessage            `json:"items"`
+		Pattern              string                     `json:"pattern"`
+		Minimum              *float64                   `json:"minimum"`
+		Maximum              *float64                   `json:"maximum"`
+		ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+		ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+		MinLength            *int                       `json:"minLength"`
+		MaxLength            *int                       `json:"maxLength"`
+		MinItems             *int                       `json:"minItems"`
+		MaxItems             *int                       `json:"maxItems"`
+	}
+
+	// FieldError describes why a single field failed validation, the
+	// field is a path such as position.x or items[2], empty for the root
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// SchemaError is returned when a value doesn't match a schema
+	SchemaError struct {
+		Fields []FieldError
+	}
+)
+
+// CompileSchema parses a json schema
+func CompileSchema(src string) (*Schema, error) {
+	return compileSchema(json.RawMessage(src))
+}
+
+// NewSchemaValidator loads and compiles the schema in the file, so
+// the schema is only compiled once when the event is defined
+func NewSchemaValidator(file string) EventValidator {
+	rs, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		panic(err)
+	}
+
+	schema, err := CompileSchema(string(rs))
+
+	if err != nil {
+		panic(err)
+	}
+
+	return EventValidator{Handler: schema.Validation, Schema: string(rs)}
+}
+
+// Validation can be used as the handler of an event validator, the
+// schema it is given is ignored as this one is already compiled
+func (s *Schema) Validation(_ string, subject interface{}) error {
+	return s.Validate(subject)
+}
+
+// Validate checks the value against the schema, a SchemaError is
+// returned listing every field that doesn't match
+func (s *Schema) Validate(v interface{}) error {
+	v, err := generic(v)
+
+	if err != nil {
+		return err
+	}
+
+	var errs []FieldError
+	s.validate("", v, &errs)
+
+	if len(errs) > 0 {
+		return &SchemaError{Fields: errs}
+	}
+
+	return nil
+}
+
+// Error lists the failed fields
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Fields))
+
+	for i, f := range e.Fields {
+		if f.Field == "" {
+			msgs[i] = f.Message
+			continue
+		}
+
+		msgs[i] = f.Field + ": " + f.Message
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Compiles a single schema and everything nested in it
+func compileSchema(data json.RawMessage) (*Schema, error) {
+	var raw rawSchema
+
+	// true allows anything and false nothing
+	switch strings.TrimSpace(string(data)) {
+	case "true":
+		return &Schema{}, nil
+	case "false":
+		return &Schema{never: true}, nil
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	s := &Schema{
+		required:         raw.Required,
+		enum:             raw.Enum,
+		minimum:          raw.Minimum,
+		maximum:          raw.Maximum,
+		exclusiveMinimum: raw.ExclusiveMinimum,
+		exclusiveMaximum: raw.ExclusiveMaximum,
+		minLength:        raw.MinLength,
+		maxLength:        raw.MaxLength,
+		minItems:         raw.MinItems,
+		maxItems:         raw.MaxItems,
+	}
+
+	if len(raw.Type) > 0 {
+		if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			var t string
+
+			if err := json.Unmarshal(raw.Type, &t); err != nil {
+				return nil, fmt.Errorf("type must be a string or an array of strings")
+			}
+
+			s.types = []string{t}
+		}
+	}
+
+	if raw.Pattern != "" {
+		p, err := regexp.Compile(raw.Pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.pattern = p
+	}
+
+	if len(raw.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(raw.Properties))
+
+		for name, prop := range raw.Properties {
+			p, err := compileSchema(prop)
+
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %s", name, err)
+			}
+
+			s.properties[name] = p
+		}
+	}
+
+	if len(raw.AdditionalProperties) > 0 {
+		additional, err := compileSchema(raw.AdditionalProperties)
+
+		if err != nil {
+			return nil, fmt.Errorf("additionalProperties: %s", err)
+		}
+
+		s.additional = additional
+	}
+
+	// items is either a schema for every item or one per position
+	if len(raw.Items) > 0 {
+		var tuple []json.RawMessage
+
+		if err := json.Unmarshal(raw.Items, &tuple); err == nil {
+			for i, item := range tuple {
+				t, err := compileSchema(item)
+
+				if err != nil {
+					return nil, fmt.Errorf("items[%d]: %s", i, err)
+				}
+
+				s.tuple = append(s.tuple, t)
+			}
+		} else if s.items, err = compileSchema(raw.Items); err != nil {
+			return nil, fmt.Errorf("items: %s", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Validates a value that has been reduced to its generic json form
+func (s *Schema) validate(path string, v interface{}, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.never {
+		fail("is not allowed")
+		return
+	}
+
+	if len(s.types) > 0 && !s.matchesType(v) {
+		fail("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+
+	if len(s.enum) > 0 && !s.inEnum(v) {
+		fail("must be one of the allowed values")
+	}
+
+	switch val := v.(type) {
+	case float64:
+		if s.minimum != nil && val < *s.minimum {
+			fail("must be at least %v", *s.minimum)
+		}
+
+		if s.maximum != nil && val > *s.maximum {
+			fail("must be at most %v", *s.maximum)
+		}
+
+		if s.exclusiveMinimum != nil && val <= *s.exclusiveMinimum {
+			fail("must be greater than %v", *s.exclusiveMinimum)
+		}
+
+		if s.exclusiveMaximum != nil && val >= *s.exclusiveMaximum {
+			fail("must be less than %v", *s.exclusiveMaximum)
+		}
+
+	case string:
+		n := utf8.RuneCountInString(val)
+
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			fail("must match %s", s.pattern)
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(val) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+
+		if s.maxItems != nil && len(val) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+
+		for i, item := range val {
+			p := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case s.items != nil:
+				s.items.validate(p, item, errs)
+			case i < len(s.tuple):
+				s.tuple[i].validate(p, item, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := val[name]; !ok {
+				*errs = append(*errs, FieldError{Field: join(path, name), Message: "is required"})
+			}
+		}
+
+		// Sorted so errors come back in a stable order
+		keys := make([]string, 0, len(val))
+
+		for k := range val {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if prop, ok := s.properties[k]; ok {
+				prop.validate(join(path, k), val[k], errs)
+				continue
+			}
+
+			if s.additional != nil {
+				s.additional.validate(join(path, k), val[k], errs)
+			}
+		}
+	}
+}
+
+// Checks the value against the allowed types
+func (s *Schema) matchesType(v interface{}) bool {
+	for _, t := range s.types {
+		switch val := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && val == math.Trunc(val)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Checks the value is one of the enum values
+func (s *Schema) inEnum(v interface{}) bool {
+	for _, e := range s.enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Joins a property onto a field path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Reduces a value to the types encoding/json decodes into, events
+// fired internally can carry structs and other go types
+func generic(v interface{}) (interface{}, error) {
+	if isGeneric(v) {
+		return v, nil
+	}
+
+	raw, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err = json.Unmarshal(raw, &out)
+
+	return out, err
+}
+
+// Checks whether the value is already in its generic json form
+func isGeneric(v interface{}) bool {
+	switch val := v.(type) {
+	case nil, bool, float64, string:
+		return true
+	case []interface{}:
+		for _, item := range val {
+			if !isGeneric(item) {
+				return false
+			}
+		}
+
+		return true
+	case map[string]interface{}:
+		for _, item := range val {
+			if !isGeneric(item) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
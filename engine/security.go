@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// CertReloader serves a certificate loaded from disk, the files
+	// are checked for changes so renewed certificates are picked up
+	// without restarting the server
+	CertReloader struct {
+		CertFile string
+		KeyFile  string
+		// Interval is how often the files are checked for changes
+		Interval time.Duration
+
+		mu      sync.Mutex
+		cert    *tls.Certificate
+		modTime time.Time
+		checked time.Time
+	}
+)
+
+// TLS versions by their config name
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewCertReloader loads the certificate and key, an error is
+// returned if they can't be loaded the first time
+func NewCertReloader(cert, key string, interval time.Duration) (*CertReloader, error) {
+	cr := &CertReloader{CertFile: cert, KeyFile: key, Interval: interval}
+
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// GetCertificate can be used as the tls config callback, the files
+// are checked at most once per interval. If a changed certificate
+// can't be loaded the previous one is kept
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.Interval > 0 && time.Since(cr.checked) >= cr.Interval {
+		cr.checked = time.Now()
+
+		if cr.changed() {
+			if err := cr.load(); err != nil && cr.cert == nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cr.cert, nil
+}
+
+// Loads the certificate under the lock
+func (cr *CertReloader) reload() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.checked = time.Now()
+	return cr.load()
+}
+
+// Checks whether either file has been modified since it was loaded
+func (cr *CertReloader) changed() bool {
+	return cr.newest().After(cr.modTime)
+}
+
+// The most recent modification time of the two files
+func (cr *CertReloader) newest() time.Time {
+	var newest time.Time
+
+	for _, f := range []string{cr.CertFile, cr.KeyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+
+	return newest
+}
+
+// Reads the certificate and key from disk
+func (cr *CertReloader) load() error {
+	modTime := cr.newest()
+	cert, err := tls.LoadX509KeyPair(cr.CertFile, cr.KeyFile)
+
+	if err != nil {
+		return err
+	}
+
+	cr.cert = &cert
+	cr.modTime = modTime
+
+	return nil
+}
+
+// TLSConfig builds the tls config from the standard config, nil is
+// returned when no certificate has been given
+func (GM *GameManager) TLSConfig() (*tls.Config, error) {
+	st := GM.Settings.TLS
+
+	if st.CertFile == "" {
+		return nil, nil
+	}
+
+	version, ok := tlsVersions[st.MinVersion]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown tls version %q", st.MinVersion)
+	}
+
+	cr, err := NewCertReloader(st.CertFile, st.KeyFile, st.Reload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{MinVersion: version, GetCertificate: cr.GetCertificate}, nil
+}
+
+// Checks the origin of a browser request against the allowed
+// origins. Without any the origin has to match the host, which
+// is the same check the upgrader makes by default
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+
+	// Requests that don't come from a browser have no origin
+	if origin == "" {
+		return true
+	}
+
+	allowed := s.GM.Settings.Server.Origins
+
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, a := range allowed {
+		if matchOrigin(a, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches an origin against an allowed pattern, a single star allows
+// everything and a leading star allows any subdomain
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || strings.EqualFold(pattern, origin) {
+		return true
+	}
+
+	if i := strings.Index(pattern, "*."); i >= 0 {
+		prefix, suffix := pattern[:i], pattern[i+1:]
+		origin = strings.ToLower(origin)
+
+		return strings.HasPrefix(origin, strings.ToLower(prefix)) &&
+			strings.HasSuffix(origin, strings.ToLower(suffix)) &&
+			len(origin) > len(prefix)+len(suffix)
+	}
+
+	return false
+}
+
+// Checks whether the origin is only allowed because every origin is
+func (s *Server) onlyStar(origin string) bool {
+	star := false
+
+	for _, a := range s.GM.Settings.Server.Origins {
+		if a == "*" {
+			star = true
+		} else if matchOrigin(a, origin) {
+			return false
+		}
+	}
+
+	return star
+}
+
+// Adds the cors headers for allowed origins and answers preflight
+// requests, false is returned when the request has been handled
+func (s *Server) cors(w http.ResponseWriter, r *http.Request) bool {
+	if !s.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return false
+	}
+
+	// Any site can use an origin only allowed by the star, so
+	// those are never given credentials
+	if origin := r.Header.Get("Origin"); origin != "" && s.onlyStar(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if r.Method != http.MethodOptions {
+		return true
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+	w.WriteHeader(http.StatusNoContent)
+
+	return false
+}
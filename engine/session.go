@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+type (
+	// Tells the server loop a suspended client's grace period is
+	// over, the epoch ties it to a single suspension
+	expiry struct {
+		client *Client
+		epoch  int
+	}
+)
+
+// Creates a random token, used for resuming and for session ids
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Gives the client a resume token when sessions can be resumed
+func (s *Server) issueToken(client *Client) {
+	if s.GM.Settings.Resume.Grace <= 0 {
+		return
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		return
+	}
+
+	client.ResumeToken = token
+	s.Sessions.Store(token, client)
+}
+
+// Checks whether a disconnecting client should be suspended
+func (s *Server) resumable(client *Client) bool {
+	return client.ResumeToken != "" && s.GM.Settings.Resume.Grace > 0 && !s.Closed()
+}
+
+// Suspends the client, keeping it on the server until either it
+// resumes or its grace period runs out
+func (s *Server) suspend(client *Client) {
+	epoch, ok := client.suspend()
+
+	if !ok {
+		return
+	}
+
+	s.GM.Log.Infof("Client %s suspended, waiting for it to resume", client.ID)
+
+	time.AfterFunc(s.GM.Settings.Resume.Grace, func() {
+		select {
+		case s.expired <- expiry{client: client, epoch: epoch}:
+		case <-s.Shutdown:
+		}
+	})
+}
+
+// Moves the connection of a freshly connected client onto the
+// suspended session it asked to resume and replays what it missed
+func (s *Server) resume(client *Client, fresh *Client) {
+	s.GM.Log.Infof("Resuming client %s", client.ID)
+
+	// The old connection may not have been noticed as gone yet
+	client.suspend()
+
+	go func() {
+		// The old writer has to finish before its queue is replaced
+		<-client.Done()
+
+		if !client.reattach(fresh.Conn, cap(fresh.Send)) {
+			// The session ended while we were waiting
+			fresh.Close()
+			s.write(fresh, fresh.done)
+			return
+		}
+
+		// The used token was dropped when the resume started
+		s.mu.Lock()
+		client.ResumeToken = ""
+		s.issueToken(client)
+		s.mu.Unlock()
+
+		// The new connection may have come from somewhere else
+		client.Metadata.merge(fresh.Metadata)
+
+		go client.Conn.Reader(client, s)
+		go s.write(client, client.done)
+
+		s.GM.FireEvent(NewDirectEvent(ResumedEvent, client, client.ID))
+	}()
+}
+
+// Holds an event for replay, once the buffer is full
+// the oldest events are dropped
+func (c *Client) hold(e Event) {
+	size := DefaultSettings().Resume.Buffer
+
+	if c.server != nil {
+		size = c.server.GM.Settings.Resume.Buffer
+	}
+
+	c.missedMu.Lock()
+	defer c.missedMu.Unlock()
+
+	c.missed = append(c.missed, e)
+
+	if len(c.missed) > size {
+		c.missed = c.missed[len(c.missed)-size:]
+	}
+}
+
+// Closes the clients connection but keeps the session, returns
+// the epoch of the suspension or false if it can't be suspended
+func (c *Client) suspend() (int, bool) {
+	c.release()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gone || c.suspended {
+		return c.epoch, false
+	}
+
+	c.suspended = true
+	c.closeSend()
+
+	// The client binds udp again once it resumes
+	c.udp = nil
+
+	return c.epoch, true
+}
+
+// Attaches a new connection to a suspended client with the events it
+// missed already queued, returns false if the client has already left
+func (c *Client) reattach(conn ConnectionInterface, size int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gone {
+		return false
+	}
+
+	c.missedMu.Lock()
+	missed := c.missed
+	c.missed = nil
+	c.missedMu.Unlock()
+
+	// The replay has to fit before anything else can be pushed
+	if len(missed) > size {
+		size = len(missed)
+	}
+
+	c.quitMu.Lock()
+	c.quit = make(chan struct{})
+	c.quitMu.Unlock()
+
+	c.Conn = conn
+	c.Send = make(chan Event, size)
+	c.done = make(chan struct{})
+	c.closed = false
+	c.suspended = false
+	c.epoch++
+
+	for _, e := range missed {
+		c.Send <- e
+	}
+
+	return true
+}
+
+// Checks whether the suspension with the given epoch is still current
+func (c *Client) expired(epoch int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.suspended && !c.gone && c.epoch == epoch
+}
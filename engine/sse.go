@@ -0,0 +1,377 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionEvent is the first server sent event on a new stream,
+	// its data is the session id used for posting and reconnecting
+	SessionEvent = "session"
+)
+
+type (
+	// SSEHandler is a fallback for clients that can't use websockets,
+	// events are streamed down with server sent events and sent up
+	// with POST requests. Sessions keep the same client between
+	// requests so components can't tell the difference
+	SSEHandler struct {
+		Server   *Server
+		Sessions *sync.Map
+	}
+
+	// SSEConnection is the connection behind an sse session, the
+	// stream it writes to changes each time the client reconnects
+	SSEConnection struct {
+		// activity is accessed atomically so is kept first
+		// for alignment
+		activity
+
+		inbound  chan Event
+		streams  chan *sseStream
+		released chan *sseStream
+		closed   chan struct{}
+	}
+
+	// A single GET request events are streamed to
+	sseStream struct {
+		w    http.ResponseWriter
+		f    http.Flusher
+		done chan struct{}
+	}
+)
+
+// NewSSEHandler creates a handler for the given server
+func NewSSEHandler(s *Server) *SSEHandler {
+	return &SSEHandler{Server: s, Sessions: new(sync.Map)}
+}
+
+// NewSSEConnection creates a connection waiting for its first stream
+func NewSSEConnection() *SSEConnection {
+	return &SSEConnection{
+		inbound:  make(chan Event),
+		streams:  make(chan *sseStream),
+		released: make(chan *sseStream),
+		closed:   make(chan struct{}),
+	}
+}
+
+// ServeHTTP streams events on GET and receives events on POST,
+// requests from origins that aren't allowed are refused
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Server.cors(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.stream(w, r)
+	case http.MethodPost:
+		h.receive(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Streams events to the client, starting a new session if
+// the request doesn't give one
+func (h *SSEHandler) stream(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	session := r.URL.Query().Get("session")
+	sc, ok := h.find(session)
+
+	if session != "" && !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	if !ok {
+		if session, sc, ok = h.open(w, r); !ok {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", SessionEvent, session)
+	f.Flush()
+
+	sc.attach(&sseStream{w: w, f: f, done: make(chan struct{})}, r)
+}
+
+// Starts a new session and registers its client
+func (h *SSEHandler) open(w http.ResponseWriter, r *http.Request) (string, *SSEConnection, bool) {
+	var ident *Identity
+	s := h.Server
+
+	// There is no way to send a first message, so the
+	// token has to come with the request
+	if s.Auth != nil {
+		var err error
+
+		if ident, err = s.Auth.Authenticate(handshakeToken(r)); err != nil {
+			s.GM.Log.Warningf("Rejected sse connection from %s: %s", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return "", nil, false
+		}
+	}
+
+	id, err := s.GenerateID()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		http.Error(w, "unable to assign client id", http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	session, err := randomToken()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	sc := NewSSEConnection()
+	client := s.newClient(sc, id)
+	client.identify(ident)
+	client.Metadata.request("sse", r)
+	client.Metadata.Set(ProtocolMeta, JSONProtocol)
+
+	select {
+	case s.Register <- client:
+	case <-s.Shutdown:
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return "", nil, false
+	}
+
+	h.Sessions.Store(session, sc)
+
+	// Forget the session once the client has gone
+	go func() {
+		<-sc.closed
+		h.Sessions.Delete(session)
+	}()
+
+	return session, sc, true
+}
+
+// Receives a single json encoded event from the client
+func (h *SSEHandler) receive(w http.ResponseWriter, r *http.Request) {
+	sc, ok := h.find(r.URL.Query().Get("session"))
+
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	var e Event
+	body := http.MaxBytesReader(w, r.Body, h.Server.GM.Settings.SSE.MaxBody)
+
+	if err := json.NewDecoder(body).Decode(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case sc.inbound <- e:
+		w.WriteHeader(http.StatusAccepted)
+	case <-sc.closed:
+		http.Error(w, "session has ended", http.StatusGone)
+	}
+}
+
+// Finds a live session
+func (h *SSEHandler) find(session string) (*SSEConnection, bool) {
+	if session == "" {
+		return nil, false
+	}
+
+	sc, ok := h.Sessions.Load(session)
+
+	if !ok {
+		return nil, false
+	}
+
+	return sc.(*SSEConnection), true
+}
+
+// Hands the stream to the writer and holds the request open until
+// the writer is finished with it or the client goes away
+func (sc *SSEConnection) attach(st *sseStream, r *http.Request) {
+	select {
+	case sc.streams <- st:
+	case <-sc.closed:
+		return
+	}
+
+	select {
+	case <-st.done:
+	case <-r.Context().Done():
+		// The writer has to let go before the handler returns
+		select {
+		case sc.released <- st:
+			<-st.done
+		case <-st.done:
+		}
+	}
+}
+
+// Reader receives the events posted by the client
+func (sc *SSEConnection) Reader(c *Client, s *Server) {
+	for {
+		select {
+		case e := <-sc.inbound:
+			sc.touch()
+			s.Receive(c, e)
+		case <-sc.closed:
+			return
+		}
+	}
+}
+
+// Writer writes events to whichever stream the client currently has
+// open, events are held while the client is between streams. Clients
+// that don't reconnect within the pong wait are disconnected
+func (sc *SSEConnection) Writer(c *Client, s *Server) {
+	hb := s.GM.Settings.Heartbeat
+
+	var (
+		stream  *sseStream
+		pending []Event
+		ping    <-chan time.Time
+		expired <-chan time.Time
+		broken  bool
+	)
+
+	defer func() {
+		if stream != nil {
+			close(stream.done)
+		}
+
+		close(sc.closed)
+	}()
+
+	if hb.PingInterval > 0 {
+		ticker := time.NewTicker(hb.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	// Starts waiting for the client to open a new stream
+	wait := func() {
+		if hb.PongWait > 0 {
+			expired = time.After(hb.PongWait)
+		}
+	}
+
+	sc.touch()
+	wait()
+
+	for {
+		select {
+		case st := <-sc.streams:
+			if stream != nil {
+				close(stream.done)
+			}
+
+			stream, expired = st, nil
+
+			for len(pending) > 0 && stream != nil {
+				if err := sc.write(s, stream, pending[0]); err != nil {
+					close(stream.done)
+					stream = nil
+					wait()
+					break
+				}
+
+				pending = pending[1:]
+			}
+
+		case st := <-sc.released:
+			if st == stream {
+				close(stream.done)
+				stream = nil
+				wait()
+			}
+
+		case event, ok := <-c.Send:
+			if !ok {
+				return
+			}
+
+			if stream != nil {
+				if err := sc.write(s, stream, event); err == nil {
+					continue
+				}
+
+				close(stream.done)
+				stream = nil
+				wait()
+			}
+
+			// Hold the event for the next stream, keeping to the queue size
+			pending = append(pending, event)
+
+			if size := cap(c.Send); size > 0 && len(pending) > size {
+				pending = pending[len(pending)-size:]
+			}
+
+		case <-ping:
+			if broken {
+				continue
+			}
+
+			if hb.MaxIdle > 0 && sc.idle() > hb.MaxIdle {
+				s.GM.Log.Infof("Client %s has been idle for too long", c.ID)
+				broken = true
+				go s.detach(c, sc)
+				continue
+			}
+
+			// Comments keep proxies from closing quiet streams
+			if stream != nil {
+				fmt.Fprint(stream.w, ": ping\n\n")
+				stream.f.Flush()
+			}
+
+		case <-expired:
+			s.GM.Log.Infof("Client %s did not reconnect its stream", c.ID)
+			expired = nil
+			broken = true
+			go s.detach(c, sc)
+		}
+	}
+}
+
+// Writes a single event to the stream, only failing to write
+// is returned since the stream can't be used after that
+func (sc *SSEConnection) write(s *Server, st *sseStream, e Event) error {
+	data, err := e.encode(JSONCodec{})
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		s.GM.Log.Errorf("Unable to process event: %+v", e)
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(st.w, "id: %s\ndata: %s\n\n", e.ID, data); err != nil {
+		return err
+	}
+
+	st.f.Flush()
+	return nil
+}
@@ -61,7 +61,7 @@ func (s *StreamManager) Register() {
 	s.GM.Server.NewChannels(map[string]ChannelInterface{StreamChan: &StreamChannel{}})
 
 	// Register event handlers
-	s.GM.RegisterHandler(StreamSaveEvent, s.OnSave)
+	s.GM.Handle(StreamSaveEvent, s.OnSave)
 	s.GM.RegisterHandler("connected", s.OnConnect)
 }
 
@@ -139,25 +139,23 @@ func (s *StreamManager) Handler(n string, h StreamHandler) {
 	s.StreamHandlers.Store(n, st)
 }
 
-// OnSave handler for save events
-func (s *StreamManager) OnSave(e Event) bool {
-	var schema StreamSchema
-
-	Decode(e.Data, &schema)
-
+// OnSave handler for save events, failures are logged rather
+// than stopping other handlers from seeing the event
+func (s *StreamManager) OnSave(e Event, schema *StreamSchema) bool {
 	// Find the stream
 	st, err := s.Find(schema.Stream)
 
 	if err != nil {
 		s.GM.Log.Error(err)
-		return false
+		return Continue
 	}
 
 	val := reflect.New(st.StructValue).Interface()
-	err = Decode(schema.Data, &val)
-
-	if err != nil {
+	// Saving a zero value would lose whatever was there
+	if err := Decode(schema.Data, &val); err != nil {
 		s.GM.Log.Error(err)
+		s.GM.Reject(e, ValidationError, err.Error())
+		return Continue
 	}
 
 	// If this is an entity, we should set the client id
@@ -170,7 +168,7 @@ func (s *StreamManager) OnSave(e Event) bool {
 	// Save the data
 	s.GM.DB.Save(st.Collection, val)
 
-	return true
+	return Continue
 }
 
 // Find does what it says on the tin
@@ -193,9 +191,9 @@ func (s *StreamManager) OnConnect(e Event) bool {
 
 	if err != nil {
 		s.GM.Log.Error(err)
-		return false
+		return Continue
 	}
 
 	s.GM.Server.ConnectTo(StreamChan, cl)
-	return true
+	return Continue
 }
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"sync"
+)
+
+const (
+	// Continue is returned by handlers to let the event carry on
+	// to lower priority handlers
+	Continue = true
+
+	// Stop is returned by handlers to keep the event from lower
+	// priority handlers, such as a guard vetoing an action
+	Stop = false
+
+	// DefaultPriority is given to handlers registered without one
+	DefaultPriority = 0
+)
+
+type (
+	// Subscription is the handle returned when a handler is
+	// registered, it can be used to remove the handler again
+	Subscription struct {
+		Name     string
+		Priority int
+
+		handler EventHandler
+		subs    *sync.Map
+	}
+
+	// Implemented by Trait, handlers it registered are removed
+	// when the trait is destroyed
+	handlerReleaser interface {
+		releaseHandlers()
+	}
+)
+
+// Guards changes to the handler slices, they are copied on write
+// so dispatch can range over them without the lock
+var subscriptionsMu sync.Mutex
+
+// Adds the handler to the event's subscriptions, higher priorities
+// come first and equal priorities keep the order they were added in
+func subscribe(subs *sync.Map, n string, p int, h EventHandler) *Subscription {
+	sub := &Subscription{Name: n, Priority: p, handler: h, subs: subs}
+
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	current := subscriptions(subs, n)
+	i := len(current)
+
+	for i > 0 && current[i-1].Priority < p {
+		i--
+	}
+
+	updated := make([]*Subscription, 0, len(current)+1)
+	updated = append(updated, current[:i]...)
+	updated = append(updated, sub)
+	updated = append(updated, current[i:]...)
+
+	subs.Store(n, updated)
+	return sub
+}
+
+// Unsubscribe removes the handler, it is safe to call more than once
+func (s *Subscription) Unsubscribe() {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	current := subscriptions(s.subs, s.Name)
+	updated := make([]*Subscription, 0, len(current))
+
+	for _, sub := range current {
+		if sub != s {
+			updated = append(updated, sub)
+		}
+	}
+
+	if len(updated) == 0 {
+		s.subs.Delete(s.Name)
+		return
+	}
+
+	s.subs.Store(s.Name, updated)
+}
+
+// The current subscriptions for the event
+func subscriptions(subs *sync.Map, n string) []*Subscription {
+	if reg, ok := subs.Load(n); ok {
+		return reg.([]*Subscription)
+	}
+
+	return nil
+}
+
+// Calls the handlers for the event in priority order until one
+// stops it, false is returned if there are no handlers
+func propagate(subs *sync.Map, e Event) bool {
+	reg, ok := subs.Load(e.Name)
+
+	if !ok {
+		return false
+	}
+
+	for _, sub := range reg.([]*Subscription) {
+		if sub.handler(e) == Stop {
+			break
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// TCP frame types, each frame is a four byte big endian length
+	// followed by the type and then the payload
+	tcpData  byte = 1
+	tcpPing  byte = 2
+	tcpPong  byte = 3
+	tcpClose byte = 4
+
+	// Close codes sent in the payload of a close frame, these
+	// match their websocket equivalents
+	tcpCloseNormal    uint16 = 1000
+	tcpCloseGoingAway uint16 = 1001
+	tcpClosePolicy    uint16 = 1008
+)
+
+var (
+	// ErrFrameTooLarge is returned when a frame is over the configured limit
+	ErrFrameTooLarge = errors.New("frame too large")
+
+	// ErrConnectionClosed is returned when the peer sends a close frame
+	ErrConnectionClosed = errors.New("connection closed by peer")
+)
+
+type (
+	// TCPConnection is a length prefixed framed connection for
+	// clients that don't speak websockets, it has the same heartbeat
+	// and close behaviour as the websocket connection
+	TCPConnection struct {
+		// activity is accessed atomically so is kept first
+		// for alignment
+		activity
+		Conn net.Conn
+		// Codec defaults to json when not set
+		Codec Codec
+		// MaxFrame limits the size of frames read from the client
+		MaxFrame int
+
+		// The reader answers pings while the writer is writing
+		writeMu sync.Mutex
+	}
+)
+
+// ListenTCP listens for framed tcp clients on the address from the
+// standard config, this should be called once the game is running.
+// Connections use tls when a certificate is configured
+func (GM *GameManager) ListenTCP() error {
+	config, err := GM.TLSConfig()
+
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", GM.Settings.TCP.Address)
+
+	if err != nil {
+		return err
+	}
+
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
+
+	if !GM.listen(func() { GM.TCP = l }) {
+		return l.Close()
+	}
+
+	GM.Log.Infof("Listening for tcp connections on %s", GM.Settings.TCP.Address)
+
+	return GM.Server.ServeTCP(l)
+}
+
+// ServeTCP accepts connections from the listener and registers each
+// one as a new client, this returns once the listener is closed.
+// Temporary errors such as running out of file descriptors are
+// retried with a backoff, like http.Server does
+func (s *Server) ServeTCP(l net.Listener) error {
+	var delay time.Duration
+
+	for {
+		conn, err := l.Accept()
+
+		if err != nil {
+			if s.Closed() {
+				return nil
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+
+				s.GM.Log.Warningf("Unable to accept tcp connection, retrying in %s: %s", delay, err)
+
+				select {
+				case <-time.After(delay):
+				case <-s.Shutdown:
+					return nil
+				}
+
+				continue
+			}
+
+			return err
+		}
+
+		delay = 0
+		go s.acceptTCP(conn)
+	}
+}
+
+// Authenticates the connection if needed and registers the client
+func (s *Server) acceptTCP(conn net.Conn) {
+	var ident *Identity
+
+	tc := &TCPConnection{
+		Conn:     conn,
+		Codec:    s.codec(s.GM.Settings.TCP.Codec),
+		MaxFrame: s.GM.Settings.TCP.MaxFrame,
+	}
+
+	id, err := s.GenerateID()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		conn.Close()
+		return
+	}
+
+	// There is no handshake, so the first frame has to be the auth event
+	if s.Auth != nil {
+		conn.SetReadDeadline(deadline(s.GM.Settings.Auth.Timeout))
+
+		t, msg, err := tc.readFrame()
+
+		if err == nil && t != tcpData {
+			err = ErrUnauthorized
+		}
+
+		if err == nil {
+			ident, err = s.authenticateEvent(msg, tc.codec())
+		}
+
+		if err != nil {
+			s.GM.Log.Warningf("Rejected tcp connection from %s: %s", conn.RemoteAddr(), err)
+			tc.refuse(tcpClosePolicy)
+			return
+		}
+
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	client := s.newClient(tc, id)
+	client.identify(ident)
+	client.Metadata.Set(TransportMeta, "tcp")
+	client.Metadata.Set(RemoteAddrMeta, conn.RemoteAddr().String())
+	client.Metadata.Set(ProtocolMeta, tc.codec().Name())
+
+	select {
+	case s.Register <- client:
+	case <-s.Shutdown:
+		tc.refuse(tcpCloseGoingAway)
+	}
+}
+
+// Reader reads frames from the client, data frames are decoded
+// with the codec and received as events
+func (tc *TCPConnection) Reader(c *Client, s *Server) {
+	hb := s.GM.Settings.Heartbeat
+	tc.touch()
+
+	for {
+		tc.Conn.SetReadDeadline(deadline(hb.PongWait))
+
+		t, msg, err := tc.readFrame()
+
+		if err != nil {
+			if err != io.EOF && err != ErrConnectionClosed {
+				s.GM.Log.Error(err)
+			}
+
+			s.detach(c, tc)
+			return
+		}
+
+		switch t {
+		case tcpPing:
+			tc.writeFrame(tcpPong, msg, hb.WriteTimeout)
+		case tcpData:
+			tc.touch()
+
+			var e Event
+
+			if err := tc.codec().Unmarshal(msg, &e); err != nil {
+				s.GM.Log.Error(err)
+				continue
+			}
+
+			s.Receive(c, e)
+		}
+	}
+}
+
+// Writer writes events to the client, it also pings the client and
+// closes the connection once it has been idle for too long
+func (tc *TCPConnection) Writer(c *Client, s *Server) {
+	hb := s.GM.Settings.Heartbeat
+	defer tc.Conn.Close()
+
+	var ping <-chan time.Time
+
+	if hb.PingInterval > 0 {
+		ticker := time.NewTicker(hb.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	// Once a write fails the connection is closed, which stops the
+	// reader, we keep draining the queue until the client is removed
+	broken := false
+
+	for {
+		select {
+		case event, ok := <-c.Send:
+			if !ok {
+				code := tcpCloseNormal
+
+				if s.Closed() {
+					code = tcpCloseGoingAway
+				}
+
+				tc.close(code, hb.WriteTimeout)
+				return
+			}
+
+			if broken {
+				continue
+			}
+
+			if err := tc.write(event, hb); err != nil {
+				s.GM.Log.Error(err)
+				s.GM.Log.Errorf("Unable to process event: %+v", event)
+				tc.Conn.Close()
+				broken = true
+			}
+
+		case <-ping:
+			if broken {
+				continue
+			}
+
+			if hb.MaxIdle > 0 && tc.idle() > hb.MaxIdle {
+				s.GM.Log.Infof("Client %s has been idle for too long", c.ID)
+				tc.Conn.Close()
+				broken = true
+				continue
+			}
+
+			if err := tc.writeFrame(tcpPing, nil, hb.WriteTimeout); err != nil {
+				s.GM.Log.Error(err)
+				tc.Conn.Close()
+				broken = true
+			}
+		}
+	}
+}
+
+// Encodes and writes a single event
+func (tc *TCPConnection) write(e Event, hb HeartbeatSettings) error {
+	data, err := e.encode(tc.codec())
+
+	if err != nil {
+		return err
+	}
+
+	return tc.writeFrame(tcpData, data, hb.WriteTimeout)
+}
+
+// The codec the connection uses
+func (tc *TCPConnection) codec() Codec {
+	if tc.Codec == nil {
+		return JSONCodec{}
+	}
+
+	return tc.Codec
+}
+
+// Reads a single frame, close frames are returned as an error
+func (tc *TCPConnection) readFrame() (byte, []byte, error) {
+	var size uint32
+
+	if err := binary.Read(tc.Conn, binary.BigEndian, &size); err != nil {
+		return 0, nil, err
+	}
+
+	if size == 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	if tc.MaxFrame > 0 && int(size) > tc.MaxFrame {
+		return 0, nil, ErrFrameTooLarge
+	}
+
+	buf := make([]byte, size)
+
+	if _, err := io.ReadFull(tc.Conn, buf); err != nil {
+		return 0, nil, err
+	}
+
+	if buf[0] == tcpClose {
+		return tcpClose, buf[1:], ErrConnectionClosed
+	}
+
+	return buf[0], buf[1:], nil
+}
+
+// Writes a single frame
+func (tc *TCPConnection) writeFrame(t byte, payload []byte, timeout time.Duration) error {
+	tc.writeMu.Lock()
+	defer tc.writeMu.Unlock()
+
+	buf := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = t
+	copy(buf[5:], payload)
+
+	tc.Conn.SetWriteDeadline(deadline(timeout))
+	_, err := tc.Conn.Write(buf)
+
+	return err
+}
+
+// Sends the close frame with the given code
+func (tc *TCPConnection) close(code uint16, timeout time.Duration) {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, code)
+
+	tc.writeFrame(tcpClose, payload, timeout)
+}
+
+// Closes a connection that never became a client
+func (tc *TCPConnection) refuse(code uint16) {
+	tc.close(code, time.Second)
+	tc.Conn.Close()
+}
@@ -1,11 +1,20 @@
 package engine
 
+import (
+	"sync"
+)
+
 type (
 	// Trait is a helper struct that contains information
 	// an instance might need
 	Trait struct {
 		Component
 		Client *Client
+
+		// Handlers registered for the client, removed when
+		// the trait is destroyed
+		handlersMu sync.Mutex
+		handlers   []*Subscription
 	}
 
 	// TraitInterface defines the expectations of an instance
@@ -38,8 +47,44 @@ func (t *Trait) SetClient(c *Client) {
 	t.Client = c
 }
 
+// PriorityHandler creates a new event handler for the client with a priority
+func (t *Trait) PriorityHandler(n string, p int, h EventHandler) *Subscription {
+	return t.track(t.Client.RegisterPriorityHandler(n, p, h))
+}
+
+// Handle registers a typed handler for the client, see TypedHandler
+func (t *Trait) Handle(n string, fn interface{}) *Subscription {
+	return t.track(t.Client.RegisterHandler(n, t.GM.TypedHandler(fn)))
+}
+
+// Metadata returns the metadata of the connected client
+func (t *Trait) Metadata() *Metadata {
+	return t.Client.Metadata
+}
+
 // Handler is an override to create a new event handler,
 // this ensures that an instance can bind to direct event messages
-func (t *Trait) Handler(n string, h EventHandler) {
-	t.Client.RegisterHandler(n, h)
+func (t *Trait) Handler(n string, h EventHandler) *Subscription {
+	return t.track(t.Client.RegisterHandler(n, h))
+}
+
+// Remembers a handler so it can be removed with the trait
+func (t *Trait) track(sub *Subscription) *Subscription {
+	t.handlersMu.Lock()
+	defer t.handlersMu.Unlock()
+
+	t.handlers = append(t.handlers, sub)
+	return sub
+}
+
+// Removes every handler the trait registered
+func (t *Trait) releaseHandlers() {
+	t.handlersMu.Lock()
+	defer t.handlersMu.Unlock()
+
+	for _, sub := range t.handlers {
+		sub.Unsubscribe()
+	}
+
+	t.handlers = nil
 }
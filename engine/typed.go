@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	eventType = reflect.TypeOf(Event{})
+	boolType  = reflect.TypeOf(true)
+)
+
+// TypedHandler turns a func taking an event and a pointer to its
+// payload, such as func(Event, *MovePayload) bool, into an event
+// handler. The payload is decoded from the event data and events
+// that can't be decoded are rejected with a validation error. This
+// panics if the func doesn't have that signature
+func (GM *GameManager) TypedHandler(fn interface{}) EventHandler {
+	fv := reflect.ValueOf(fn)
+	payload := typedPayload(fv.Type())
+
+	return func(e Event) bool {
+		p := reflect.New(payload)
+
+		if err := Decode(e.Data, p.Interface()); err != nil {
+			GM.Log.Errorf("Unable to decode %s: %s", e.Name, err)
+			GM.Reject(e, ValidationError, err.Error())
+			return Continue
+		}
+
+		return fv.Call([]reflect.Value{reflect.ValueOf(e), p})[0].Bool()
+	}
+}
+
+// Handle registers a typed handler for the event, see TypedHandler
+func (GM *GameManager) Handle(n string, fn interface{}) *Subscription {
+	return GM.RegisterHandler(n, GM.TypedHandler(fn))
+}
+
+// Checks the signature of a typed handler, returning the payload type
+func typedPayload(t reflect.Type) reflect.Type {
+	if t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.In(0) != eventType || t.In(1).Kind() != reflect.Ptr ||
+		t.NumOut() != 1 || t.Out(0) != boolType {
+		panic(fmt.Sprintf("typed handlers must be func(Event, *T) bool, got %s", t))
+	}
+
+	return t.In(1).Elem()
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+const (
+	// UDP packet types, the first byte of every packet. Bind packets
+	// carry the clients udp token, data packets carry an eight byte
+	// big endian sequence number followed by the encoded event
+	udpBind  byte = 1
+	udpData  byte = 2
+	udpBound byte = 3
+)
+
+type (
+	// The udp address a client has bound, along with the sequence
+	// numbers used to drop stale packets in each direction
+	udpBinding struct {
+		conn net.PacketConn
+		addr net.Addr
+
+		mu  sync.Mutex
+		out uint64
+		in  uint64
+	}
+)
+
+// ListenUDP listens for udp packets on the address from the standard
+// config, this should be called once the game is running
+func (GM *GameManager) ListenUDP() error {
+	pc, err := net.ListenPacket("udp", GM.Settings.UDP.Address)
+
+	if err != nil {
+		return err
+	}
+
+	if !GM.listen(func() { GM.UDP = pc }) {
+		return pc.Close()
+	}
+
+	GM.Log.Infof("Listening for udp packets on %s", GM.Settings.UDP.Address)
+
+	return GM.Server.ServeUDP(pc)
+}
+
+// ServeUDP reads packets from the connection, clients that connect
+// after this has started are given a token to bind their udp address
+// with. Events marked unreliable are then sent to them over udp
+func (s *Server) ServeUDP(pc net.PacketConn) error {
+	s.mu.Lock()
+	s.udp = pc
+	s.mu.Unlock()
+
+	buf := make([]byte, s.GM.Settings.UDP.MaxPacket)
+
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+
+		if err != nil {
+			if s.Closed() {
+				return nil
+			}
+
+			return err
+		}
+
+		if n > 0 {
+			s.packet(buf[0], buf[1:n], addr)
+		}
+	}
+}
+
+// Handles a single packet
+func (s *Server) packet(t byte, payload []byte, addr net.Addr) {
+	switch t {
+	case udpBind:
+		cl, ok := s.udpTokens.Load(string(payload))
+
+		if !ok {
+			s.GM.Log.Warningf("Unknown udp token from %s", addr)
+			return
+		}
+
+		client := cl.(*Client)
+
+		if !client.bindUDP(s.udp, addr) {
+			return
+		}
+
+		s.udpClients.Store(addr.String(), client)
+		s.udp.WriteTo([]byte{udpBound}, addr)
+
+	case udpData:
+		cl, ok := s.udpClients.Load(addr.String())
+
+		if !ok || len(payload) < 8 {
+			return
+		}
+
+		client := cl.(*Client)
+		b := client.udpBinding()
+
+		// The client has since rebound or dropped its connection
+		if b == nil || b.addr.String() != addr.String() {
+			s.udpClients.Delete(addr.String())
+			return
+		}
+
+		if !b.accept(binary.BigEndian.Uint64(payload)) {
+			return
+		}
+
+		var e Event
+
+		if err := s.codec(s.GM.Settings.UDP.Codec).Unmarshal(payload[8:], &e); err != nil {
+			s.GM.Log.Error(err)
+			return
+		}
+
+		s.Receive(client, e)
+	}
+}
+
+// Gives the client a token to bind its udp address with, this
+// only happens when the server is reading udp packets
+func (s *Server) issueUDPToken(client *Client) {
+	if s.udp == nil {
+		return
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		s.GM.Log.Error(err)
+		return
+	}
+
+	client.UDPToken = token
+	s.udpTokens.Store(token, client)
+}
+
+// Removes the udp token and binding of a client that has left
+func (s *Server) removeUDP(client *Client) {
+	if client.UDPToken == "" {
+		return
+	}
+
+	s.udpTokens.Delete(client.UDPToken)
+
+	if b := client.udpBinding(); b != nil {
+		s.udpClients.Delete(b.addr.String())
+	}
+}
+
+// Binds the clients udp address, rebinding replaces the old address
+// so clients can move between networks. False is returned if the
+// client can't currently be bound
+func (c *Client) bindUDP(pc net.PacketConn, addr net.Addr) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	c.udp = &udpBinding{conn: pc, addr: addr}
+	return true
+}
+
+// The clients current udp binding, if it has one
+func (c *Client) udpBinding() *udpBinding {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.udp
+}
+
+// Sends the event in a data packet
+func (b *udpBinding) send(e Event, codec Codec) error {
+	data, err := e.encode(codec)
+
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.out++
+	seq := b.out
+	b.mu.Unlock()
+
+	buf := make([]byte, 9+len(data))
+	buf[0] = udpData
+	binary.BigEndian.PutUint64(buf[1:], seq)
+	copy(buf[9:], data)
+
+	_, err = b.conn.WriteTo(buf, b.addr)
+	return err
+}
+
+// Checks the sequence number of an inbound packet, anything older
+// than the newest packet already seen is stale and dropped
+func (b *udpBinding) accept(seq uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq <= b.in {
+		return false
+	}
+
+	b.in = seq
+	return true
+}
@@ -0,0 +1,96 @@
+package engine
+
+const (
+	// AllowDuplicates lets a user have any number of sessions
+	AllowDuplicates = "allow"
+
+	// KickDuplicates ends the older sessions when a user connects again
+	KickDuplicates = "kick"
+
+	// RejectDuplicates turns away a new session while an older one is live
+	RejectDuplicates = "reject"
+)
+
+// FindUser returns all of the live sessions of a user, including
+// those waiting to resume
+func (s *Server) FindUser(id string) []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Client(nil), s.users[id]...)
+}
+
+// Adds the client to its users sessions, called under the lock
+func (s *Server) addSession(client *Client) {
+	if client.UserID == "" {
+		client.UserID = client.ID
+	}
+
+	s.users[client.UserID] = append(s.users[client.UserID], client)
+}
+
+// Removes the client from its users sessions
+func (s *Server) removeSession(client *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := s.users[client.UserID]
+
+	for i, c := range sessions {
+		if c == client {
+			sessions = append(sessions[:i], sessions[i+1:]...)
+			break
+		}
+	}
+
+	if len(sessions) == 0 {
+		delete(s.users, client.UserID)
+		return
+	}
+
+	s.users[client.UserID] = sessions
+}
+
+// Applies the duplicate policy to an identified client, returning the
+// older sessions to end and whether the client can connect at all.
+// Called under the lock
+func (s *Server) duplicates(client *Client) ([]*Client, bool) {
+	if s.Auth == nil || client.UserID == "" {
+		return nil, true
+	}
+
+	sessions := s.users[client.UserID]
+
+	switch s.GM.Settings.Users.Duplicates {
+	case KickDuplicates:
+		return append([]*Client(nil), sessions...), true
+
+	case RejectDuplicates:
+		// Sessions waiting to resume don't stop a fresh login
+		for _, c := range sessions {
+			if !c.Suspended() {
+				return nil, false
+			}
+		}
+	}
+
+	return nil, true
+}
+
+// Ends sessions that have been replaced by a newer login
+func (s *Server) kick(sessions []*Client) {
+	for _, c := range sessions {
+		s.GM.Log.Infof("Client %s has signed in elsewhere, disconnecting", c.ID)
+		c.Push(duplicateSession(c))
+		s.leave(c)
+	}
+}
+
+// The error sent to a session that is ended or turned away
+// because of another session of the same user
+func duplicateSession(client *Client) Event {
+	return NewDirectEvent(ErrorEvent, ErrorPayload{
+		Code:    DuplicateSessionError,
+		Message: "signed in from another session",
+	}, client.ID)
+}
@@ -0,0 +1,170 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// DialAs connects with a token for the given claims
+func DialAs(t *testing.T, url string, claims engine.Claims) *websocket.Conn {
+	token, err := engine.SignToken("secret", claims)
+	assert.Nil(t, err)
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ws
+}
+
+// ReadPosition reads a queue position event
+func ReadPosition(t *testing.T, ws *websocket.Conn) engine.QueuePosition {
+	var e struct {
+		Name string               `json:"name"`
+		Data engine.QueuePosition `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, engine.QueuePositionEvent, e.Name)
+
+	return e.Data
+}
+
+func TestClientsOverTheCapAreQueued(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Admission.MaxClients = 1
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+
+	second := DialAs(t, url, engine.Claims{"sub": "player-2"})
+	defer second.Close()
+	assert.Equal(t, engine.QueuePosition{Position: 1, Waiting: 1}, ReadPosition(t, second))
+
+	third := DialAs(t, url, engine.Claims{"sub": "player-3"})
+	defer third.Close()
+	assert.Equal(t, engine.QueuePosition{Position: 2, Waiting: 2}, ReadPosition(t, third))
+
+	assert.Empty(t, gm.Server.FindUser("player-2"))
+
+	// Once the first client leaves the next in line takes its slot
+	gm.Server.Unregister <- gm.Server.FindUser("player-1")[0]
+
+	assert.Nil(t, second.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.Len(t, gm.Server.FindUser("player-2"), 1)
+
+	assert.Equal(t, engine.QueuePosition{Position: 1, Waiting: 1}, ReadPosition(t, third))
+}
+
+func TestPriorityClientsUseReservedSlots(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Admission.MaxClients = 2
+	gm.Settings.Admission.Reserved = 1
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+
+	// The only slot left is reserved
+	second := DialAs(t, url, engine.Claims{"sub": "player-2"})
+	defer second.Close()
+	assert.Equal(t, 1, ReadPosition(t, second).Position)
+
+	vip := DialAs(t, url, engine.Claims{"sub": "vip-1", "priority": true})
+	defer vip.Close()
+
+	assert.Nil(t, vip.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.Equal(t, gm.Server.FindUser("vip-1")[0].ID, e.ClientID)
+}
+
+func TestQueuedClientsCanLeave(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Admission.MaxClients = 1
+	gm.Settings.Admission.Interval = 20 * time.Millisecond
+
+	disconnected := make(chan string, 2)
+	gm.RegisterHandler(engine.DisconnectedEvent, func(e engine.Event) bool {
+		disconnected <- e.ClientID
+		return true
+	})
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+
+	second := DialAs(t, url, engine.Claims{"sub": "player-2"})
+	ReadPosition(t, second)
+	second.Close()
+
+	third := DialAs(t, url, engine.Claims{"sub": "player-3"})
+	defer third.Close()
+
+	// The second client may not have been noticed as gone yet,
+	// but it is soon no longer ahead in the queue
+	for ReadPosition(t, third).Position != 1 {
+	}
+
+	client := gm.Server.FindUser("player-1")[0]
+	gm.Server.Unregister <- client
+	assert.Equal(t, client.ID, <-disconnected)
+
+	assert.Nil(t, third.ReadJSON(&e))
+	assert.Equal(t, gm.Server.FindUser("player-3")[0].ID, e.ClientID)
+	assert.Empty(t, disconnected)
+}
+
+func TestSlowQueuedClientsDontBlockTheServer(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Queue = engine.QueueSettings{Size: 0, Policy: engine.BlockPolicy}
+	gm.Settings.Admission.MaxClients = 1
+	gm.Settings.Admission.Interval = 10 * time.Millisecond
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+
+	// Never read from, so its writer stops taking positions
+	second := DialAs(t, url, engine.Claims{"sub": "player-2"})
+	defer second.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	third := DialAs(t, url, engine.Claims{"sub": "player-3"})
+	defer third.Close()
+	third.SetReadDeadline(time.Now().Add(time.Second))
+
+	assert.Equal(t, 2, ReadPosition(t, third).Position)
+	assert.Len(t, gm.Server.FindUser("player-1"), 1)
+}
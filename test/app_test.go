@@ -55,9 +55,7 @@ func (t *TestConnection) Reader(c *engine.Client, s *engine.Server) {
 	for {
 		select {
 		case e := <-t.Out:
-			e.ClientID = c.ID
-			e.Origin = engine.ClientOrigin
-			s.GM.FireEvent(e)
+			s.Receive(c, e)
 			break
 		}
 	}
@@ -157,6 +155,13 @@ func BenchmarkStreamEventFullCycle(b *testing.B) {
 	app.GM.DB.Settings = engine.MongoSettings{Host: "localhost", Database: "test", AutoConnect: true}
 	app.GM.DB.Connect()
 
+	// Saves are server only by default
+	app.GM.Event(engine.EventDefinition{
+		Name:     engine.StreamSaveEvent,
+		Channels: []string{engine.InternalChan},
+		Origins:  []string{engine.ClientOrigin},
+	})
+
 	for i := 0; i < b.N; i++ {
 		done := make(chan bool)
 
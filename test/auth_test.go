@@ -0,0 +1,125 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// StartAuthServer runs an app that requires tokens signed with the secret
+func StartAuthServer(secret string) (*engine.GameManager, *httptest.Server) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Server.Auth = engine.NewHMACAuthenticator(secret)
+
+	return gm, httptest.NewServer(gm.Server)
+}
+
+func TestHandshakeTokenSetsIdentity(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+
+	token, err := engine.SignToken("secret", engine.Claims{"sub": "player-1", "role": "admin"})
+	assert.Nil(t, err)
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var e struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, "player-1", e.Data.UserID)
+
+	sessions := gm.Server.FindUser("player-1")
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, e.ClientID, sessions[0].ID)
+	assert.Equal(t, "admin", sessions[0].Claims.String("role"))
+}
+
+func TestInvalidTokenIsRejected(t *testing.T) {
+	_, srv := StartAuthServer("secret")
+	defer srv.Close()
+
+	token, _ := engine.SignToken("not-the-secret", engine.Claims{"sub": "player-1"})
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?token="+token, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestFirstMessageAuthentication(t *testing.T) {
+	_, srv := StartAuthServer("secret")
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	token, _ := engine.SignToken("secret", engine.Claims{"sub": "player-2"})
+	assert.Nil(t, ws.WriteJSON(engine.NewEvent(engine.AuthEvent, map[string]string{"token": token})))
+
+	var e struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.Equal(t, "player-2", e.Data.UserID)
+}
+
+// Serves websockets through GM.Connect instead of the server handler
+func ConnectServer(t *testing.T, gm *engine.GameManager, errs chan error) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Upgrade(w, r, nil, 0, 0)
+		assert.Nil(t, err)
+
+		errs <- gm.Connect(ws, "player-3")
+	}))
+}
+
+func TestConnectRequiresAuthentication(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Server.Auth = engine.NewHMACAuthenticator("secret")
+
+	errs := make(chan error, 1)
+	srv := ConnectServer(t, gm, errs)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	assert.Nil(t, ws.WriteJSON(engine.NewEvent("test.hello", nil)))
+	assert.Equal(t, engine.ErrUnauthorized, <-errs)
+	assert.Len(t, gm.Server.FindUser("player-3"), 0)
+}
+
+func TestConnectIdentifiesClient(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Server.Auth = engine.NewHMACAuthenticator("secret")
+
+	errs := make(chan error, 1)
+	srv := ConnectServer(t, gm, errs)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	token, _ := engine.SignToken("secret", engine.Claims{"sub": "player-4"})
+	assert.Nil(t, ws.WriteJSON(engine.NewEvent(engine.AuthEvent, map[string]string{"token": token})))
+	assert.Nil(t, <-errs)
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.Len(t, gm.Server.FindUser("player-4"), 1)
+}
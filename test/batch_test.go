@@ -0,0 +1,119 @@
+package test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsAreBatchedIntoArrayFrames(t *testing.T) {
+	gm := engine.NewGame()
+	gm.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	gm.Run()
+	gm.Settings.Batch = engine.BatchSettings{Size: 3, Interval: 100 * time.Millisecond}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	// The connected event has nothing to share its frame with
+	var connected []engine.Event
+	assert.Nil(t, ws.ReadJSON(&connected))
+	assert.Len(t, connected, 1)
+
+	client, _ := gm.Server.Find(connected[0].ClientID)
+
+	for i := 0; i < 3; i++ {
+		client.Push(engine.NewDirectEvent("test.direct", i, client.ID))
+	}
+
+	var batch []engine.Event
+	assert.Nil(t, ws.ReadJSON(&batch))
+	assert.Len(t, batch, 3)
+	assert.Equal(t, float64(0), batch[0].Data)
+	assert.Equal(t, float64(2), batch[2].Data)
+}
+
+func TestQueuedEventsAreCoalesced(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Event(engine.EventDefinition{
+		Name:     "test.position",
+		Channels: []string{engine.DirectChan},
+		Coalesce: func(e engine.Event) string {
+			return fmt.Sprint(e.Data.(map[string]int)["entity"])
+		},
+	})
+	gm.Settings.Batch = engine.BatchSettings{Size: 10, Interval: 200 * time.Millisecond}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var connected []engine.Event
+	assert.Nil(t, ws.ReadJSON(&connected))
+
+	id := connected[0].ClientID
+	positions := []map[string]int{
+		{"entity": 1, "x": 1},
+		{"entity": 2, "x": 1},
+		{"entity": 1, "x": 2},
+	}
+
+	// Events are fired concurrently so give each time to be queued
+	for _, p := range positions {
+		gm.FireEvent(engine.NewDirectEvent("test.position", p, id))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var batch []struct {
+		Data map[string]int `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&batch))
+	assert.Len(t, batch, 2)
+	assert.Equal(t, 2, batch[0].Data["entity"])
+	assert.Equal(t, 2, batch[1].Data["x"])
+}
+
+func TestCoalesceKeysAreScopedToTheEvent(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	entity := func(e engine.Event) string {
+		return "entity-1"
+	}
+
+	gm.Event(engine.EventDefinition{Name: "test.pos", Channels: []string{engine.DirectChan}, Coalesce: entity})
+	gm.Event(engine.EventDefinition{Name: "test.hp", Channels: []string{engine.DirectChan}, Coalesce: entity})
+	gm.Settings.Batch = engine.BatchSettings{Size: 10, Interval: 200 * time.Millisecond}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var connected []engine.Event
+	assert.Nil(t, ws.ReadJSON(&connected))
+
+	id := connected[0].ClientID
+	gm.FireEvent(engine.NewDirectEvent("test.pos", 1, id))
+	time.Sleep(20 * time.Millisecond)
+	gm.FireEvent(engine.NewDirectEvent("test.hp", 2, id))
+
+	var batch []engine.Event
+	assert.Nil(t, ws.ReadJSON(&batch))
+	assert.Len(t, batch, 2)
+	assert.Equal(t, "test.pos", batch[0].Name)
+	assert.Equal(t, "test.hp", batch[1].Name)
+}
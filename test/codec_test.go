@@ -0,0 +1,62 @@
+package test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	codec := engine.MsgPackCodec{}
+	in := engine.NewDirectEvent("test.msgpack", map[string]interface{}{
+		"x":     -12,
+		"y":     1.5,
+		"big":   70000,
+		"name":  strings.Repeat("a", 300),
+		"flags": []interface{}{true, false, nil},
+	}, "client-1")
+
+	data, err := codec.Marshal(in)
+	assert.Nil(t, err)
+
+	var out engine.Event
+	assert.Nil(t, codec.Unmarshal(data, &out))
+
+	assert.Equal(t, in.ID, out.ID)
+	assert.Equal(t, in.ClientID, out.ClientID)
+	assert.True(t, in.CreatedAt.Equal(out.CreatedAt))
+	assert.Equal(t, map[string]interface{}{
+		"x":     float64(-12),
+		"y":     1.5,
+		"big":   float64(70000),
+		"name":  strings.Repeat("a", 300),
+		"flags": []interface{}{true, false, nil},
+	}, out.Data)
+}
+
+func TestCodecNegotiatedBySubprotocol(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{engine.MsgPackProtocol}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	assert.Equal(t, engine.MsgPackProtocol, ws.Subprotocol())
+
+	mt, data, err := ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.BinaryMessage, mt)
+
+	var e engine.Event
+	assert.Nil(t, engine.MsgPackCodec{}.Unmarshal(data, &e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionIsNegotiated(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Compression.Enabled = true
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	dialer := websocket.Dialer{EnableCompression: true}
+	ws, resp, err := dialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	assert.Contains(t, resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+
+	// Both sides of the minimum size arrive intact
+	client, _ := gm.Server.Find(e.ClientID)
+	large := strings.Repeat("chunk", 1000)
+	client.Push(engine.NewDirectEvent("test.direct", large, client.ID))
+	client.Push(engine.NewDirectEvent("test.direct", "small", client.ID))
+
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, large, e.Data)
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, "small", e.Data)
+}
+
+func TestCompressionIsOffByDefault(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	dialer := websocket.Dialer{EnableCompression: true}
+	ws, resp, err := dialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	assert.Empty(t, resp.Header.Get("Sec-Websocket-Extensions"))
+}
+
+func TestConnectUsesCompressionSettings(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Compression.Enabled = true
+
+	up := websocket.Upgrader{EnableCompression: true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := up.Upgrade(w, r, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, gm.Connect(ws, "player-1"))
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	dialer := websocket.Dialer{EnableCompression: true}
+	ws, _, err := dialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+
+	client, _ := gm.Server.Find(e.ClientID)
+	assert.Equal(t, gm.Settings.Compression, client.Conn.(*engine.WebsocketConnection).Compression)
+}
@@ -1,7 +1,10 @@
 package test
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Danzabar/gorge/engine"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +19,7 @@ type (
 func (t *TestEvents) Register() {
 	t.Event("test.direct", []string{engine.DirectChan})
 	t.Event("test.internal", []string{engine.InternalChan})
+	t.ClientEvent("test.client", []string{engine.InternalChan})
 }
 
 func TestEventFiresOnConnection(t *testing.T) {
@@ -70,3 +74,189 @@ func TestDirectEvent(t *testing.T) {
 	app.GM.FireEvent(engine.NewDirectEvent("test.direct", "", "test-123"))
 	<-done
 }
+
+// NextEvent waits for the next event sent to the client with the given name
+func NextEvent(app *ApplicationTest, n string) engine.Event {
+	for {
+		e := <-app.Connection.In
+
+		if e.Name == n {
+			return e
+		}
+	}
+}
+
+func TestClientCannotFireServerEvents(t *testing.T) {
+	app := StartNewAppTest("test-124")
+
+	sent := engine.NewEvent(engine.ConnectedEvent, nil)
+	app.Connection.Out <- sent
+
+	e := NextEvent(app, engine.ErrorEvent)
+	payload := e.Data.(engine.ErrorPayload)
+
+	assert.Equal(t, engine.ForbiddenError, payload.Code)
+	assert.Equal(t, sent.ID, payload.EventID)
+}
+
+func TestClientCanFireClientEvents(t *testing.T) {
+	app := NewApplicationTest("test-125")
+	done := make(chan bool)
+	app.GM.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+
+	app.GM.RegisterHandler("test.client", func(e engine.Event) bool {
+		assert.Equal(t, engine.ClientOrigin, e.Origin)
+		done <- true
+		return true
+	})
+
+	app.Start()
+	app.Connection.Out <- engine.NewEvent("test.client", nil)
+	<-done
+}
+
+func TestClientOverRateLimitIsWarned(t *testing.T) {
+	app := NewApplicationTest("test-126")
+	limited := make(chan bool, 1)
+	app.GM.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	app.GM.RegisterHandler(engine.RateLimitedEvent, func(e engine.Event) bool {
+		limited <- true
+		return true
+	})
+
+	app.GM.Run()
+	app.GM.Settings.RateLimit = engine.RateLimitSettings{
+		RateLimit: engine.RateLimit{Rate: 0.001, Burst: 1},
+		Action:    engine.WarnAction,
+	}
+	app.GM.Server.Register <- app.Client
+
+	app.Connection.Out <- engine.NewEvent("test.client", nil)
+	app.Connection.Out <- engine.NewEvent("test.client", nil)
+
+	e := NextEvent(app, engine.ErrorEvent)
+	assert.Equal(t, engine.RateLimitedError, e.Data.(engine.ErrorPayload).Code)
+	<-limited
+}
+
+func TestRateLimitIsReportedOnceWhenGoingOver(t *testing.T) {
+	app := NewApplicationTest("test-129")
+	var hooks int32
+	done := make(chan bool, 1)
+	app.GM.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	app.GM.RegisterHandler(engine.RateLimitedEvent, func(e engine.Event) bool {
+		atomic.AddInt32(&hooks, 1)
+		return true
+	})
+	app.GM.RegisterHandler("test.client", func(e engine.Event) bool {
+		done <- true
+		return true
+	})
+
+	app.GM.Run()
+	app.GM.Event(engine.EventDefinition{
+		Name:      "test.flood",
+		Channels:  []string{engine.InternalChan},
+		Origins:   []string{engine.ClientOrigin},
+		RateLimit: &engine.RateLimit{Rate: 0.001, Burst: 1},
+	})
+	app.GM.Server.Register <- app.Client
+
+	for i := 0; i < 10; i++ {
+		app.Connection.Out <- engine.NewEvent("test.flood", nil)
+	}
+
+	app.Connection.Out <- engine.NewEvent("test.client", nil)
+	<-done
+
+	rejected := 0
+	timeout := time.After(100 * time.Millisecond)
+
+	for waiting := true; waiting; {
+		select {
+		case e := <-app.Connection.In:
+			if e.Name == engine.ErrorEvent {
+				rejected++
+			}
+		case <-timeout:
+			waiting = false
+		}
+	}
+
+	assert.Equal(t, 1, rejected)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hooks))
+}
+
+func TestRefusedEventsDontUseTheGlobalLimit(t *testing.T) {
+	app := NewApplicationTest("test-130")
+	received := make(chan string, 4)
+	app.GM.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	app.GM.RegisterHandler("test.client", func(e engine.Event) bool {
+		received <- e.Name
+		return true
+	})
+	app.GM.RegisterHandler("test.flood", func(e engine.Event) bool {
+		received <- e.Name
+		return true
+	})
+
+	app.GM.Run()
+	app.GM.Settings.RateLimit = engine.RateLimitSettings{
+		RateLimit: engine.RateLimit{Rate: 0.001, Burst: 2},
+		Action:    engine.DropAction,
+	}
+
+	// Only a rate is given, so one event is allowed at a time
+	app.GM.Event(engine.EventDefinition{
+		Name:      "test.flood",
+		Channels:  []string{engine.InternalChan},
+		Origins:   []string{engine.ClientOrigin},
+		RateLimit: &engine.RateLimit{Rate: 0.001},
+	})
+	app.GM.Server.Register <- app.Client
+
+	for i := 0; i < 3; i++ {
+		app.Connection.Out <- engine.NewEvent("test.flood", nil)
+	}
+
+	app.Connection.Out <- engine.NewEvent("test.client", nil)
+
+	assert.ElementsMatch(t, []string{"test.flood", "test.client"}, []string{<-received, <-received})
+}
+
+func TestUnknownEventIsReportedToClient(t *testing.T) {
+	app := StartNewAppTest("test-127")
+
+	sent := engine.NewEvent("does.not.exist", nil)
+	app.Connection.Out <- sent
+
+	payload := NextEvent(app, engine.ErrorEvent).Data.(engine.ErrorPayload)
+	assert.Equal(t, engine.UnknownEventError, payload.Code)
+	assert.Equal(t, sent.ID, payload.EventID)
+}
+
+func TestInvalidEventIsReportedToClient(t *testing.T) {
+	app := StartNewAppTest("test-128")
+	app.GM.Event(engine.EventDefinition{
+		Name:     "test.validated",
+		Channels: []string{engine.InternalChan},
+		Origins:  []string{engine.ClientOrigin},
+		Validator: engine.EventValidator{Handler: func(schema string, subject interface{}) error {
+			return errors.New("name is required")
+		}},
+	})
+
+	app.Connection.Out <- engine.NewEvent("test.validated", nil)
+
+	payload := NextEvent(app, engine.ErrorEvent).Data.(engine.ErrorPayload)
+	assert.Equal(t, engine.ValidationError, payload.Code)
+	assert.Equal(t, "name is required", payload.Message)
+}
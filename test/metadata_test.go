@@ -0,0 +1,71 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataIsPopulatedOnConnect(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Metadata.Expose = []string{engine.LocaleMeta, engine.TransportMeta}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	header := http.Header{
+		"Accept-Language": {"fr-CA,fr;q=0.9,en;q=0.8"},
+		"User-Agent":      {"gorge-test"},
+	}
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var connected struct {
+		engine.Event
+		Data struct {
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&connected))
+
+	// Only the exposed keys are sent to the client
+	assert.Equal(t, map[string]interface{}{"locale": "fr-CA", "transport": "websocket"}, connected.Data.Metadata)
+
+	client, _ := gm.Server.Find(connected.ClientID)
+	assert.Equal(t, "gorge-test", client.Metadata.String(engine.UserAgentMeta))
+	assert.Equal(t, engine.JSONProtocol, client.Metadata.String(engine.ProtocolMeta))
+	assert.NotEmpty(t, client.Metadata.String(engine.RemoteAddrMeta))
+
+	at, ok := client.Metadata.Get(engine.ConnectedAtMeta)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), at.(time.Time), time.Second)
+}
+
+func TestMetadataStore(t *testing.T) {
+	m := engine.NewMetadata("name")
+	m.Set("name", "Danza")
+	m.Set("secret", 42)
+
+	v, ok := m.Get("secret")
+	assert.True(t, ok)
+	assert.Equal(t, 42, v)
+	assert.Len(t, m.All(), 2)
+
+	m.Delete("secret")
+	_, ok = m.Get("secret")
+	assert.False(t, ok)
+
+	data, err := json.Marshal(m)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name":"Danza"}`, string(data))
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareCanChangeAndStopEvents(t *testing.T) {
+	gm := engine.NewGame()
+	received := make(chan engine.Event, 2)
+	order := []string{}
+
+	gm.Event(engine.EventDefinition{Name: "test.internal", Channels: []string{engine.InternalChan}})
+	gm.RegisterHandler("test.internal", func(e engine.Event) bool {
+		received <- e
+		return true
+	})
+
+	gm.Use(func(next engine.Dispatch) engine.Dispatch {
+		return func(e engine.Event) {
+			order = append(order, "outer")
+
+			// Short circuit anything marked as blocked
+			if e.Data == "blocked" {
+				return
+			}
+
+			next(e)
+		}
+	}, func(next engine.Dispatch) engine.Dispatch {
+		return func(e engine.Event) {
+			order = append(order, "inner")
+			e.Data = "enriched " + e.Data.(string)
+			next(e)
+		}
+	})
+
+	gm.FireEvent(engine.NewEvent("test.internal", "blocked"))
+	gm.FireEvent(engine.NewEvent("test.internal", "payload"))
+
+	assert.Equal(t, "enriched payload", (<-received).Data)
+	assert.Equal(t, []string{"outer", "outer", "inner"}, order)
+
+	select {
+	case e := <-received:
+		t.Fatalf("blocked event was dispatched: %v", e.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestChannelMiddlewareOnlyWrapsItsChannel(t *testing.T) {
+	gm := engine.NewGame()
+	internal := make(chan engine.Event, 1)
+	direct := make(chan engine.Event, 1)
+
+	gm.Event(engine.EventDefinition{Name: "test.both", Channels: []string{engine.InternalChan, engine.DirectChan}})
+	gm.RegisterHandler("test.both", func(e engine.Event) bool {
+		internal <- e
+		return true
+	})
+	gm.UseChannel(engine.DirectChan, func(next engine.ChannelDispatch) engine.ChannelDispatch {
+		return func(e engine.Event, d engine.EventDefinition) {
+			direct <- e
+		}
+	})
+
+	gm.FireEvent(engine.NewDirectEvent("test.both", "hello", "unknown"))
+
+	assert.Equal(t, "hello", (<-internal).Data)
+	assert.Equal(t, "hello", (<-direct).Data)
+}
+
+func TestPanickingMiddlewareIsRecovered(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Event(engine.EventDefinition{Name: "test.internal", Channels: []string{engine.InternalChan}})
+	gm.Use(func(next engine.Dispatch) engine.Dispatch {
+		return func(e engine.Event) {
+			panic("middleware failed")
+		}
+	})
+
+	assert.NotPanics(t, func() {
+		gm.FireEvent(engine.NewEvent("test.internal", nil))
+	})
+}
+
+func TestMiddlewareRunsBeforeTheEventIsChecked(t *testing.T) {
+	gm := engine.NewGame()
+	received := make(chan engine.Event, 1)
+
+	gm.Event(engine.EventDefinition{
+		Name:     "test.v2",
+		Channels: []string{engine.InternalChan},
+		Validator: engine.EventValidator{Handler: func(schema string, subject interface{}) error {
+			if subject != "upgraded" {
+				return errors.New("data must be upgraded")
+			}
+
+			return nil
+		}},
+	})
+	gm.RegisterHandler("test.v2", func(e engine.Event) bool {
+		received <- e
+		return true
+	})
+
+	// Old clients still send test.v1, which has no definition
+	gm.Use(func(next engine.Dispatch) engine.Dispatch {
+		return func(e engine.Event) {
+			if e.Name == "test.v1" {
+				e.Name = "test.v2"
+				e.Data = "upgraded"
+			}
+
+			next(e)
+		}
+	})
+
+	gm.FireEvent(engine.NewEvent("test.v1", "legacy"))
+
+	e := <-received
+	assert.Equal(t, "test.v2", e.Name)
+	assert.Equal(t, "upgraded", e.Data)
+}
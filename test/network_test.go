@@ -0,0 +1,241 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// Dial connects a websocket client to the test server
+func Dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ws
+}
+
+func TestServerUpgradesAndAssignsID(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Server.GenerateID = func() (string, error) {
+		return "generated-1", nil
+	}
+	gm.Run()
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.Equal(t, "generated-1", e.ClientID)
+
+	_, err := gm.Server.Find("generated-1")
+	assert.Nil(t, err)
+}
+
+func TestListenersAfterShutdownAreClosed(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Settings.Server.Address = "127.0.0.1:0"
+	gm.Settings.TCP.Address = "127.0.0.1:0"
+	gm.Settings.UDP.Address = "127.0.0.1:0"
+	gm.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, gm.Shutdown(ctx))
+
+	assert.Nil(t, gm.ListenTCP())
+	assert.Nil(t, gm.ListenUDP())
+	assert.Equal(t, http.ErrServerClosed, gm.ListenAndServe())
+	assert.Nil(t, gm.TCP)
+	assert.Nil(t, gm.UDP)
+	assert.Nil(t, gm.HTTP)
+}
+
+func TestConnectAfterShutdownIsRefused(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, gm.Shutdown(ctx))
+
+	errs := make(chan error, 1)
+	srv := ConnectServer(t, gm, errs)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, engine.ErrServerClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("connect is still waiting to register the client")
+	}
+
+	_, _, err := ws.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
+
+func TestShutdownDisconnectsClients(t *testing.T) {
+	gm := engine.NewGame()
+	disconnected := make(chan string, 1)
+
+	gm.RegisterHandler(engine.DisconnectedEvent, func(e engine.Event) bool {
+		disconnected <- e.ClientID
+		return true
+	})
+	gm.Run()
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Nil(t, gm.Shutdown(ctx))
+	assert.Equal(t, e.ClientID, <-disconnected)
+
+	_, _, err := ws.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
+
+func TestHeartbeatDropsUnresponsiveClients(t *testing.T) {
+	gm := engine.NewGame()
+	disconnected := make(chan string, 1)
+
+	gm.RegisterHandler(engine.DisconnectedEvent, func(e engine.Event) bool {
+		disconnected <- e.ClientID
+		return true
+	})
+	gm.Run()
+	gm.Settings.Heartbeat = engine.HeartbeatSettings{
+		PingInterval: 20 * time.Millisecond,
+		PongWait:     50 * time.Millisecond,
+		WriteTimeout: 50 * time.Millisecond,
+	}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	// Never reading means pings are never answered
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("client was not disconnected")
+	}
+}
+
+func TestClientResumesAndReplaysMissedEvents(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Resume.Grace = time.Second
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+
+	var connected struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&connected))
+	assert.NotEmpty(t, connected.Data.ResumeToken)
+
+	// Drop the connection and send something while the client is away
+	client, _ := gm.Server.Find(connected.ClientID)
+	gm.Server.Disconnect(client)
+	ws.Close()
+
+	assert.True(t, client.Suspended())
+	client.Push(engine.NewDirectEvent("test.direct", "missed", client.ID))
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "?resume=" + connected.Data.ResumeToken
+	resumed, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer resumed.Close()
+
+	var e engine.Event
+	assert.Nil(t, resumed.ReadJSON(&e))
+	assert.Equal(t, "test.direct", e.Name)
+	assert.Equal(t, "missed", e.Data)
+
+	assert.Nil(t, resumed.ReadJSON(&e))
+	assert.Equal(t, engine.ResumedEvent, e.Name)
+	assert.Equal(t, client.ID, e.ClientID)
+}
+
+func TestConnectCanResumeWithANewToken(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Resume.Grace = time.Second
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Upgrade(w, r, nil, 0, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, gm.ConnectResume(ws, "player-1", r.URL.Query().Get("resume")))
+	}))
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+
+	var connected struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&connected))
+	token := connected.Data.ResumeToken
+
+	client, _ := gm.Server.Find(connected.ClientID)
+	gm.Server.Disconnect(client)
+	ws.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "?resume=" + token
+	resumed, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer resumed.Close()
+
+	var e struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+	assert.Nil(t, resumed.ReadJSON(&e))
+	assert.Equal(t, engine.ResumedEvent, e.Name)
+	assert.Equal(t, connected.ClientID, e.ClientID)
+	assert.NotEmpty(t, e.Data.ResumeToken)
+	assert.NotEqual(t, token, e.Data.ResumeToken)
+
+	// The used token starts a new session
+	again, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer again.Close()
+
+	assert.Nil(t, again.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+	assert.NotEqual(t, connected.ClientID, e.ClientID)
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlersRunByPriority(t *testing.T) {
+	gm := engine.NewGame()
+	calls := make(chan string, 10)
+	done := make(chan bool, 2)
+
+	gm.Event(engine.EventDefinition{Name: "test.action", Channels: []string{engine.InternalChan}})
+
+	gm.RegisterHandler("test.action", func(e engine.Event) bool {
+		calls <- "gameplay"
+		return engine.Continue
+	})
+	gm.RegisterHandler("test.action", func(e engine.Event) bool {
+		done <- true
+		return engine.Continue
+	})
+
+	// The guard is registered last but runs first, vetoing actions
+	gm.RegisterPriorityHandler("test.action", 10, func(e engine.Event) bool {
+		calls <- "guard"
+
+		if e.Data == "cheat" {
+			done <- true
+			return engine.Stop
+		}
+
+		return engine.Continue
+	})
+
+	gm.FireEvent(engine.NewEvent("test.action", "cheat"))
+	<-done
+
+	gm.FireEvent(engine.NewEvent("test.action", "move"))
+	<-done
+
+	close(calls)
+	order := []string{}
+
+	for c := range calls {
+		order = append(order, c)
+	}
+
+	assert.Equal(t, []string{"guard", "guard", "gameplay"}, order)
+}
+
+func TestTraitHandlersRunByPriority(t *testing.T) {
+	client := engine.NewClient(nil, "test")
+	order := []int{}
+
+	for _, p := range []int{0, 5, -1, 5} {
+		p := p
+		client.RegisterPriorityHandler("test.direct", p, func(e engine.Event) bool {
+			order = append(order, p)
+			return p != -1
+		})
+	}
+
+	client.RegisterPriorityHandler("test.direct", -5, func(e engine.Event) bool {
+		t.Fatal("propagation was not stopped")
+		return engine.Continue
+	})
+
+	engine.SendToTraits(client, engine.NewEvent("test.direct", nil))
+	assert.Equal(t, []int{5, 5, 0, -1}, order)
+}
+
+func TestFailingHandlersDontStopLaterOnes(t *testing.T) {
+	gm := engine.NewGame()
+	connected := make(chan string, 1)
+	saved := make(chan bool, 1)
+
+	// The stream manager can't find the client, which is an error
+	// rather than a reason to hide the event from other handlers
+	gm.StreamManager.Register()
+	gm.RegisterHandler(engine.ConnectedEvent, func(e engine.Event) bool {
+		connected <- e.ClientID
+		return engine.Continue
+	})
+
+	// Neither should a typed handler that can't decode the payload
+	gm.RegisterHandler(engine.StreamSaveEvent, func(e engine.Event) bool {
+		saved <- true
+		return engine.Continue
+	})
+
+	gm.FireEvent(engine.NewDirectEvent(engine.ConnectedEvent, nil, "unknown"))
+	gm.FireEvent(engine.NewEvent(engine.StreamSaveEvent, "not a schema"))
+
+	assert.Equal(t, "unknown", <-connected)
+	assert.True(t, <-saved)
+}
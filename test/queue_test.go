@@ -0,0 +1,82 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropOldestKeepsNewestEvents(t *testing.T) {
+	c := engine.NewQueuedClient(NewTestConnection(), "test", engine.QueueSettings{Size: 2, Policy: engine.DropOldestPolicy})
+
+	for _, n := range []string{"one", "two", "three"} {
+		assert.True(t, c.Push(engine.NewEvent(n, nil)))
+	}
+
+	assert.Equal(t, 2, c.Depth())
+	assert.Equal(t, "two", (<-c.Send).Name)
+	assert.Equal(t, "three", (<-c.Send).Name)
+}
+
+func TestDropNewestKeepsQueuedEvents(t *testing.T) {
+	c := engine.NewQueuedClient(NewTestConnection(), "test", engine.QueueSettings{Size: 1, Policy: engine.DropNewestPolicy})
+
+	assert.True(t, c.Push(engine.NewEvent("one", nil)))
+	assert.False(t, c.Push(engine.NewEvent("two", nil)))
+
+	assert.Equal(t, 1, c.Depth())
+	assert.Equal(t, "one", (<-c.Send).Name)
+}
+
+func TestSlowConsumerIsDisconnected(t *testing.T) {
+	conn := NewTestConnection()
+	app := &ApplicationTest{
+		GM:         engine.NewGame(),
+		Client:     engine.NewQueuedClient(conn, "slow", engine.QueueSettings{Size: 1, Policy: engine.DisconnectPolicy}),
+		Connection: conn,
+	}
+	done := make(chan bool, 1)
+
+	app.GM.RegisterHandler(engine.DisconnectedEvent, func(e engine.Event) bool {
+		done <- true
+		return true
+	})
+
+	app.Start()
+
+	// Nothing reads from the test connection, so the queue fills up
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+			app.GM.Server.Broadcast(engine.NewEvent("flood", nil))
+		}
+	}
+}
+
+func TestQueueSettingsAreValidated(t *testing.T) {
+	st := engine.DefaultSettings()
+	assert.Nil(t, st.Validate())
+
+	st.Queue = engine.QueueSettings{Size: 10, Policy: "drop-everything"}
+	assert.NotNil(t, st.Validate())
+
+	st.Queue = engine.QueueSettings{Size: 0, Policy: engine.DropOldestPolicy}
+	assert.NotNil(t, st.Validate())
+
+	st.Queue = engine.QueueSettings{Size: 0, Policy: engine.BlockPolicy}
+	assert.Nil(t, st.Validate())
+}
+
+func TestPolicyNamesAreValidated(t *testing.T) {
+	st := engine.DefaultSettings()
+	st.RateLimit.Action = "shout"
+	assert.NotNil(t, st.Validate())
+
+	st = engine.DefaultSettings()
+	st.Users.Duplicates = "ignore"
+	assert.NotNil(t, st.Validate())
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	TestResponder struct {
+		engine.Component
+	}
+)
+
+func (t *TestResponder) Register() {
+	t.ClientEvent("test.add", []string{})
+	t.ClientEvent("test.fail", []string{})
+
+	t.Respond("test.add", func(e engine.Event) (interface{}, error) {
+		nums := e.Data.([]int)
+		return nums[0] + nums[1], nil
+	})
+
+	t.Respond("test.fail", func(e engine.Event) (interface{}, error) {
+		return nil, errors.New("nope")
+	})
+}
+
+func StartResponderApp(c string) *ApplicationTest {
+	app := NewApplicationTest(c)
+	app.GM.AddComponents(map[string]engine.ComponentInterface{
+		"responder": &TestResponder{},
+	})
+
+	app.Start()
+	return app
+}
+
+func TestClientRequestGetsReply(t *testing.T) {
+	app := StartResponderApp("test-rpc-1")
+
+	req := engine.NewEvent("test.add", []int{1, 2})
+	req.CorrelationID = "req-1"
+	app.Connection.Out <- req
+
+	e := NextEvent(app, engine.ReplyEvent)
+	assert.Equal(t, "req-1", e.CorrelationID)
+	assert.Equal(t, 3, e.Data)
+}
+
+func TestFailedClientRequestGetsError(t *testing.T) {
+	app := StartResponderApp("test-rpc-2")
+
+	req := engine.NewEvent("test.fail", nil)
+	req.CorrelationID = "req-2"
+	app.Connection.Out <- req
+
+	e := NextEvent(app, engine.ErrorEvent)
+	assert.Equal(t, "req-2", e.CorrelationID)
+	assert.Equal(t, engine.RequestFailedError, e.Data.(engine.ErrorPayload).Code)
+}
+
+func TestInternalRequest(t *testing.T) {
+	app := StartResponderApp("test-rpc-3")
+
+	res, err := app.GM.Request("test.add", []int{2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, res)
+
+	_, err = app.GM.Request("test.missing", nil)
+	assert.Equal(t, engine.ErrNoResponder, err)
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+const moveSchema = `{
+	"type": "object",
+	"required": ["x", "y", "direction"],
+	"additionalProperties": false,
+	"properties": {
+		"x": {"type": "integer", "minimum": 0, "maximum": 100},
+		"y": {"type": "integer", "minimum": 0, "maximum": 100},
+		"direction": {"enum": ["north", "east", "south", "west"]},
+		"name": {"type": "string", "pattern": "^[a-z]+$", "maxLength": 8},
+		"path": {"type": "array", "maxItems": 2, "items": {"type": "number"}}
+	}
+}`
+
+func TestSchemaValidatesCoreKeywords(t *testing.T) {
+	schema, err := engine.CompileSchema(moveSchema)
+	assert.Nil(t, err)
+
+	assert.Nil(t, schema.Validate(map[string]interface{}{
+		"x": float64(1), "y": float64(2), "direction": "north", "name": "dave", "path": []interface{}{1.5, 2.0},
+	}))
+
+	err = schema.Validate(map[string]interface{}{
+		"x":     1.5,
+		"y":     float64(200),
+		"name":  "Dave",
+		"path":  []interface{}{"a", 1.0, 2.0},
+		"speed": float64(3),
+	})
+
+	assert.Equal(t, []engine.FieldError{
+		{Field: "direction", Message: "is required"},
+		{Field: "name", Message: "must match ^[a-z]+$"},
+		{Field: "path", Message: "must have at most 2 items"},
+		{Field: "path[0]", Message: "must be of type number"},
+		{Field: "speed", Message: "is not allowed"},
+		{Field: "x", Message: "must be of type integer"},
+		{Field: "y", Message: "must be at most 100"},
+	}, err.(*engine.SchemaError).Fields)
+}
+
+func TestSchemaValidatesGoValues(t *testing.T) {
+	schema, err := engine.CompileSchema(`{"type": "object", "properties": {"x": {"type": "integer"}}}`)
+	assert.Nil(t, err)
+
+	assert.Nil(t, schema.Validate(map[string]int{"x": 1}))
+	assert.NotNil(t, schema.Validate(map[string]string{"x": "1"}))
+
+}
+
+func TestSchemaValidatorsCompileWhenCreated(t *testing.T) {
+	f, err := ioutil.TempFile("", "schema")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"type": 1}`)
+	f.Close()
+
+	assert.Panics(t, func() {
+		engine.NewSchemaValidator(f.Name())
+	})
+}
+
+func TestSchemasCanBeBooleans(t *testing.T) {
+	schema, err := engine.CompileSchema(`{"properties": {"legacy": false, "extra": true}}`)
+	assert.Nil(t, err)
+
+	assert.Nil(t, schema.Validate(map[string]interface{}{"extra": "anything"}))
+
+	err = schema.Validate(map[string]interface{}{"legacy": 1.0})
+	assert.Equal(t, []engine.FieldError{
+		{Field: "legacy", Message: "is not allowed"},
+	}, err.(*engine.SchemaError).Fields)
+
+	never, err := engine.CompileSchema(`false`)
+	assert.Nil(t, err)
+	assert.NotNil(t, never.Validate("hello"))
+}
+
+func TestInvalidSchemasDontCompile(t *testing.T) {
+	_, err := engine.CompileSchema(`{"type": 1}`)
+	assert.NotNil(t, err)
+
+	_, err = engine.CompileSchema(`{"pattern": "("}`)
+	assert.NotNil(t, err)
+}
+
+func TestSchemaErrorsAreSentToClients(t *testing.T) {
+	f, err := ioutil.TempFile("", "schema")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(moveSchema)
+	f.Close()
+
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Event(engine.EventDefinition{
+		Name:      "test.move",
+		Channels:  []string{engine.InternalChan},
+		Origins:   []string{engine.ClientOrigin},
+		Validator: engine.NewSchemaValidator(f.Name()),
+	})
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+
+	assert.Nil(t, ws.WriteJSON(engine.NewEvent("test.move", map[string]interface{}{"x": -1, "y": 1, "direction": "up"})))
+
+	payload := ReadError(t, ws)
+	assert.Equal(t, engine.ValidationError, payload.Code)
+	assert.Equal(t, []engine.FieldError{
+		{Field: "direction", Message: "must be one of the allowed values"},
+		{Field: "x", Message: "must be at least 0"},
+	}, payload.Fields)
+}
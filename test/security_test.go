@@ -0,0 +1,172 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// WriteCert writes a self signed certificate and key for the host
+func WriteCert(t *testing.T, dir, host string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	cert, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return cert, keyFile
+}
+
+func TestOriginsAreChecked(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Server.Origins = []string{"https://game.example.com", "https://*.example.org"}
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for origin, allowed := range map[string]bool{
+		"https://game.example.com": true,
+		"https://eu.example.org":   true,
+		"https://example.org":      false,
+		"https://evil.com":         false,
+	} {
+		ws, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+
+		if allowed {
+			assert.Nil(t, err, origin)
+			ws.Close()
+			continue
+		}
+
+		assert.NotNil(t, err, origin)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, origin)
+	}
+}
+
+func TestSSEAnswersPreflightRequests(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Server.Origins = []string{"https://game.example.com"}
+
+	srv := httptest.NewServer(engine.NewSSEHandler(gm.Server))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL, nil)
+	req.Header.Set("Origin", "https://game.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://game.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Methods"), "POST")
+
+	req.Header.Set("Origin", "https://evil.com")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestStarOriginsAreNotGivenCredentials(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+	gm.Settings.Server.Origins = []string{"https://game.example.com", "*"}
+
+	srv := httptest.NewServer(engine.NewSSEHandler(gm.Server))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL, nil)
+	req.Header.Set("Origin", "https://evil.com")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Credentials"))
+
+	req.Header.Set("Origin", "https://game.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://game.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCertificatesAreReloaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorge-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cert, key := WriteCert(t, dir, "one.example.com")
+	cr, err := engine.NewCertReloader(cert, key, time.Millisecond)
+	assert.Nil(t, err)
+
+	first, err := cr.GetCertificate(nil)
+	assert.Nil(t, err)
+
+	// Renew the certificate, making sure the modification time moves on
+	WriteCert(t, dir, "two.example.com")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(cert, later, later)
+	os.Chtimes(key, later, later)
+	time.Sleep(2 * time.Millisecond)
+
+	second, err := cr.GetCertificate(nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.Certificate[0], second.Certificate[0])
+
+	leaf, err := x509.ParseCertificate(second.Certificate[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "two.example.com", leaf.Subject.CommonName)
+}
+
+func TestTLSConfigUsesSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorge-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gm := engine.NewGame()
+	config, err := gm.TLSConfig()
+	assert.Nil(t, err)
+	assert.Nil(t, config)
+
+	cert, key := WriteCert(t, dir, "game.example.com")
+	gm.Settings.TLS = engine.TLSSettings{CertFile: cert, KeyFile: key, MinVersion: "1.3"}
+
+	config, err = gm.TLSConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(0x0304), config.MinVersion)
+
+	gm.Settings.TLS.MinVersion = "2.0"
+	_, err = gm.TLSConfig()
+	assert.NotNil(t, err)
+}
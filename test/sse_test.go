@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// ReadSSE reads the next server sent event, returning its data
+func ReadSSE(t *testing.T, r *bufio.Reader) string {
+	var data string
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimRight(line, "\n")
+
+		if line == "" && data != "" {
+			return data
+		}
+
+		if strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSSEClientStreamsAndPosts(t *testing.T) {
+	gm := engine.NewGame()
+	received := make(chan engine.Event, 1)
+	gm.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	gm.RegisterHandler("test.client", func(e engine.Event) bool {
+		received <- e
+		return true
+	})
+	gm.Run()
+
+	srv := httptest.NewServer(engine.NewSSEHandler(gm.Server))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	stream := bufio.NewReader(resp.Body)
+	session := ReadSSE(t, stream)
+
+	var connected engine.Event
+	assert.Nil(t, json.Unmarshal([]byte(ReadSSE(t, stream)), &connected))
+	assert.Equal(t, engine.ConnectedEvent, connected.Name)
+
+	body, _ := json.Marshal(engine.NewEvent("test.client", nil))
+	post, err := http.Post(srv.URL+"?session="+session, "application/json", bytes.NewReader(body))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, post.StatusCode)
+
+	assert.Equal(t, connected.ClientID, (<-received).ClientID)
+}
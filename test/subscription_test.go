@@ -0,0 +1,83 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	TestTrait struct {
+		engine.Trait
+		calls     int
+		destroyed bool
+	}
+)
+
+func (t *TestTrait) Connect() {
+	t.Handler("test.direct", func(e engine.Event) bool {
+		t.calls++
+		return engine.Continue
+	})
+}
+
+// Destroy is overridden, the handlers are still removed
+func (t *TestTrait) Destroy() {
+	t.destroyed = true
+}
+
+func TestHandlersCanBeUnsubscribed(t *testing.T) {
+	client := engine.NewClient(nil, "test")
+	calls := []string{}
+
+	first := client.RegisterHandler("test.direct", func(e engine.Event) bool {
+		calls = append(calls, "first")
+		return engine.Continue
+	})
+	client.RegisterHandler("test.direct", func(e engine.Event) bool {
+		calls = append(calls, "second")
+		return engine.Continue
+	})
+
+	engine.SendToTraits(client, engine.NewEvent("test.direct", nil))
+
+	first.Unsubscribe()
+	first.Unsubscribe()
+
+	engine.SendToTraits(client, engine.NewEvent("test.direct", nil))
+	assert.Equal(t, []string{"first", "second", "second"}, calls)
+}
+
+func TestGlobalHandlersCanBeUnsubscribed(t *testing.T) {
+	gm := engine.NewGame()
+
+	sub := gm.RegisterHandler("test.internal", func(e engine.Event) bool {
+		return engine.Continue
+	})
+	assert.Equal(t, "test.internal", sub.Name)
+
+	_, ok := gm.Subscribers.Load("test.internal")
+	assert.True(t, ok)
+
+	sub.Unsubscribe()
+
+	_, ok = gm.Subscribers.Load("test.internal")
+	assert.False(t, ok)
+}
+
+func TestRemovingATraitRemovesItsHandlers(t *testing.T) {
+	gm := engine.NewGame()
+	client := engine.NewClient(nil, "test")
+	trait := &TestTrait{}
+
+	gm.PutTrait("test", trait, client)
+	engine.SendToTraits(client, engine.NewEvent("test.direct", nil))
+	assert.Equal(t, 1, trait.calls)
+
+	gm.RemoveTrait("test", client)
+	assert.True(t, trait.destroyed)
+
+	engine.SendToTraits(client, engine.NewEvent("test.direct", nil))
+	assert.Equal(t, 1, trait.calls)
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// Fails its first accept with a temporary error
+	FlakyListener struct {
+		net.Listener
+		failed bool
+	}
+
+	TemporaryError struct{}
+)
+
+func (TemporaryError) Error() string   { return "too many open files" }
+func (TemporaryError) Timeout() bool   { return false }
+func (TemporaryError) Temporary() bool { return true }
+
+func (l *FlakyListener) Accept() (net.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, TemporaryError{}
+	}
+
+	return l.Listener.Accept()
+}
+
+// ReadFrame reads a single length prefixed frame
+func ReadFrame(t *testing.T, conn net.Conn) (byte, []byte) {
+	var size uint32
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, size)
+
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf[0], buf[1:]
+}
+
+// WriteFrame writes a data frame containing the json encoded event
+func WriteFrame(t *testing.T, conn net.Conn, e engine.Event) {
+	data, _ := json.Marshal(e)
+	buf := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(data)))
+	buf[4] = 1
+	copy(buf[5:], data)
+
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTCPClientsShareComponents(t *testing.T) {
+	gm := engine.NewGame()
+	received := make(chan engine.Event, 1)
+	gm.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestEvents{},
+	})
+	gm.RegisterHandler("test.client", func(e engine.Event) bool {
+		received <- e
+		return true
+	})
+	gm.Run()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go gm.Server.ServeTCP(l)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ft, data := ReadFrame(t, conn)
+	assert.Equal(t, byte(1), ft)
+
+	var connected engine.Event
+	assert.Nil(t, json.Unmarshal(data, &connected))
+	assert.Equal(t, engine.ConnectedEvent, connected.Name)
+
+	WriteFrame(t, conn, engine.NewEvent("test.client", nil))
+	assert.Equal(t, connected.ClientID, (<-received).ClientID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, gm.Shutdown(ctx))
+
+	ft, data = ReadFrame(t, conn)
+	assert.Equal(t, byte(4), ft)
+	assert.Equal(t, uint16(1001), binary.BigEndian.Uint16(data))
+}
+
+func TestTCPAcceptRetriesTemporaryErrors(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	go gm.Server.ServeTCP(&FlakyListener{Listener: l})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ft, data := ReadFrame(t, conn)
+	assert.Equal(t, byte(1), ft)
+
+	var connected engine.Event
+	assert.Nil(t, json.Unmarshal(data, &connected))
+	assert.Equal(t, engine.ConnectedEvent, connected.Name)
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	MovePayload struct {
+		X int `mapstructure:"x"`
+		Y int `mapstructure:"y"`
+	}
+)
+
+func TestTypedHandlersDecodePayloads(t *testing.T) {
+	gm := engine.NewGame()
+	moves := make(chan *MovePayload, 1)
+	gm.Run()
+	gm.Event(engine.EventDefinition{
+		Name:     "test.move",
+		Channels: []string{engine.InternalChan},
+		Origins:  []string{engine.ClientOrigin},
+	})
+	gm.Handle("test.move", func(e engine.Event, p *MovePayload) bool {
+		moves <- p
+		return true
+	})
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	ws := Dial(t, srv)
+	defer ws.Close()
+
+	var e engine.Event
+	assert.Nil(t, ws.ReadJSON(&e))
+
+	assert.Nil(t, ws.WriteJSON(engine.NewEvent("test.move", map[string]int{"x": 1, "y": 2})))
+	assert.Equal(t, &MovePayload{X: 1, Y: 2}, <-moves)
+
+	// A payload that doesn't decode is rejected rather than zeroed
+	bad := engine.NewEvent("test.move", map[string]string{"x": "left"})
+	assert.Nil(t, ws.WriteJSON(bad))
+
+	payload := ReadError(t, ws)
+	assert.Equal(t, engine.ValidationError, payload.Code)
+	assert.Equal(t, bad.ID, payload.EventID)
+	assert.Empty(t, moves)
+}
+
+func TestTypedHandlersCheckTheirSignature(t *testing.T) {
+	gm := engine.NewGame()
+
+	assert.Panics(t, func() {
+		gm.Handle("test.move", func(e engine.Event, p MovePayload) bool { return true })
+	})
+	assert.Panics(t, func() {
+		gm.Handle("test.move", func(p *MovePayload) bool { return true })
+	})
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	TestUnreliableEvents struct {
+		engine.Component
+	}
+)
+
+func (t *TestUnreliableEvents) Register() {
+	t.UnreliableEvent("test.position", []string{engine.DirectChan})
+	t.ClientEvent("test.client", []string{engine.InternalChan})
+}
+
+// ReadPacket reads a single udp packet
+func ReadPacket(t *testing.T, conn net.Conn) []byte {
+	buf := make([]byte, 1400)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	n, err := conn.Read(buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf[:n]
+}
+
+// WritePacket writes a data packet containing the json encoded event
+func WritePacket(t *testing.T, conn net.Conn, seq uint64, e engine.Event) {
+	data, _ := json.Marshal(e)
+	buf := make([]byte, 9+len(data))
+	buf[0] = 2
+	binary.BigEndian.PutUint64(buf[1:], seq)
+	copy(buf[9:], data)
+
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnreliableEventsAreSentOverUDP(t *testing.T) {
+	gm := engine.NewGame()
+	received := make(chan engine.Event, 2)
+	gm.AddComponents(map[string]engine.ComponentInterface{
+		"test": &TestUnreliableEvents{},
+	})
+	gm.RegisterHandler("test.client", func(e engine.Event) bool {
+		received <- e
+		return true
+	})
+	gm.Run()
+	gm.Settings.UDP.Codec = engine.JSONProtocol
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer pc.Close()
+
+	go gm.Server.ServeUDP(pc)
+
+	srv := httptest.NewServer(gm.Server)
+	defer srv.Close()
+
+	// Tokens are only issued once the server is reading packets
+	var connected struct {
+		engine.Event
+		Data engine.Client `json:"data"`
+	}
+
+	for i := 0; i < 50 && connected.Data.UDPToken == ""; i++ {
+		ws := Dial(t, srv)
+		defer ws.Close()
+
+		assert.Nil(t, ws.ReadJSON(&connected))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NotEmpty(t, connected.Data.UDPToken)
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write(append([]byte{1}, connected.Data.UDPToken...))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3}, ReadPacket(t, conn))
+
+	gm.FireEvent(engine.NewDirectEvent("test.position", "here", connected.ClientID))
+
+	packet := ReadPacket(t, conn)
+	assert.Equal(t, byte(2), packet[0])
+	assert.Equal(t, uint64(1), binary.BigEndian.Uint64(packet[1:]))
+
+	var e engine.Event
+	assert.Nil(t, json.Unmarshal(packet[9:], &e))
+	assert.Equal(t, "test.position", e.Name)
+	assert.Equal(t, "here", e.Data)
+
+	// The stale packet is dropped
+	WritePacket(t, conn, 2, engine.NewEvent("test.client", "new"))
+	WritePacket(t, conn, 1, engine.NewEvent("test.client", "old"))
+	WritePacket(t, conn, 3, engine.NewEvent("test.client", "newer"))
+
+	// Handlers run concurrently so the order isn't guaranteed
+	got := []interface{}{}
+
+	for i := 0; i < 2; i++ {
+		e := <-received
+		assert.Equal(t, connected.ClientID, e.ClientID)
+		got = append(got, e.Data)
+	}
+
+	assert.ElementsMatch(t, []interface{}{"new", "newer"}, got)
+
+	select {
+	case e := <-received:
+		t.Fatalf("stale packet was not dropped: %v", e.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
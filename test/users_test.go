@@ -0,0 +1,156 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Danzabar/gorge/engine"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// ReadError reads an error event
+func ReadError(t *testing.T, ws *websocket.Conn) engine.ErrorPayload {
+	var e struct {
+		Name string              `json:"name"`
+		Data engine.ErrorPayload `json:"data"`
+	}
+	assert.Nil(t, ws.ReadJSON(&e))
+	assert.Equal(t, engine.ErrorEvent, e.Name)
+
+	return e.Data
+}
+
+func TestUserEventsReachEverySession(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Event(engine.EventDefinition{Name: "test.direct", Channels: []string{engine.DirectChan}})
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+	second := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer second.Close()
+
+	var a, b engine.Event
+	assert.Nil(t, first.ReadJSON(&a))
+	assert.Nil(t, second.ReadJSON(&b))
+	assert.NotEqual(t, a.ClientID, b.ClientID)
+	assert.Len(t, gm.Server.FindUser("player-1"), 2)
+
+	gm.FireEvent(engine.NewUserEvent("test.direct", "hello", "player-1"))
+
+	for _, ws := range []*websocket.Conn{first, second} {
+		var e engine.Event
+		assert.Nil(t, ws.ReadJSON(&e))
+		assert.Equal(t, "test.direct", e.Name)
+		assert.Equal(t, "hello", e.Data)
+	}
+}
+
+func TestDuplicateSessionsCanBeKicked(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Users.Duplicates = engine.KickDuplicates
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+
+	second := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer second.Close()
+
+	assert.Equal(t, engine.DuplicateSessionError, ReadError(t, first).Code)
+	_, _, err := first.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	assert.Nil(t, second.ReadJSON(&e))
+	assert.Equal(t, engine.ConnectedEvent, e.Name)
+
+	sessions := gm.Server.FindUser("player-1")
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, e.ClientID, sessions[0].ID)
+}
+
+func TestDuplicateSessionsCanBeRejected(t *testing.T) {
+	gm, srv := StartAuthServer("secret")
+	defer srv.Close()
+	gm.Settings.Users.Duplicates = engine.RejectDuplicates
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	first := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer first.Close()
+
+	var e engine.Event
+	assert.Nil(t, first.ReadJSON(&e))
+
+	second := DialAs(t, url, engine.Claims{"sub": "player-1"})
+	defer second.Close()
+
+	assert.Equal(t, engine.DuplicateSessionError, ReadError(t, second).Code)
+	_, _, err := second.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	sessions := gm.Server.FindUser("player-1")
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, e.ClientID, sessions[0].ID)
+}
+
+func TestConnectGivesEachSessionItsOwnID(t *testing.T) {
+	gm := engine.NewGame()
+	disconnected := make(chan string, 1)
+	gm.RegisterHandler(engine.DisconnectedEvent, func(e engine.Event) bool {
+		disconnected <- e.ClientID
+		return true
+	})
+	gm.Run()
+
+	errs := make(chan error, 2)
+	srv := ConnectServer(t, gm, errs)
+	defer srv.Close()
+
+	first := Dial(t, srv)
+	defer first.Close()
+	second := Dial(t, srv)
+	defer second.Close()
+
+	var a, b engine.Event
+	assert.Nil(t, first.ReadJSON(&a))
+	assert.Nil(t, second.ReadJSON(&b))
+	assert.NotEqual(t, a.ClientID, b.ClientID)
+	assert.Len(t, gm.Server.FindUser("player-3"), 2)
+
+	client, err := gm.Server.Find(a.ClientID)
+	assert.Nil(t, err)
+	gm.Server.Unregister <- client
+	assert.Equal(t, a.ClientID, <-disconnected)
+
+	_, err = gm.Server.Find(b.ClientID)
+	assert.Nil(t, err)
+	assert.Len(t, gm.Server.FindUser("player-3"), 1)
+}
+
+func TestClientIDsCannotBeShared(t *testing.T) {
+	gm := engine.NewGame()
+	gm.Run()
+
+	first := engine.NewClient(NewTestConnection(), "client-1")
+	second := engine.NewClient(NewTestConnection(), "client-1")
+	gm.Server.Register <- first
+	gm.Server.Register <- second
+
+	// The server loop has handled the second client once it
+	// takes the next message
+	gm.Server.Unregister <- second
+
+	client, err := gm.Server.Find("client-1")
+	assert.Nil(t, err)
+	assert.Same(t, first, client)
+
+	sessions := gm.Server.FindUser("client-1")
+	assert.Len(t, sessions, 1)
+	assert.Same(t, first, sessions[0])
+}